@@ -3,190 +3,315 @@
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
-	"strings"
 	"unicode/utf8"
 
 	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
 	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/constants"
 	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
 	"github.com/spf13/cobra"
-	"golang.org/x/mod/semver"
 )
 
+// Status of a tool after checking it
+type toolStatus string
+
+type toolResult struct {
+	tool   ToolSpec
+	path   string
+	found  string
+	status toolStatus
+	// Reason if the version couldn't be found
+	reason string
+}
+
 const (
 	GO           = "go"
 	GoLangCILint = "golangci-lint"
 )
 
-func checkIfInstalled(tool string) bool {
-	str, err := exec.LookPath(tool)
+const (
+	statusOK       toolStatus = "ok"
+	statusMissing  toolStatus = "missing"
+	statusMismatch toolStatus = "mismatch"
+	statusUnknown  toolStatus = "unknown-version"
+)
 
-	if err != nil {
-		color.Printf(
-			color.ErrorColor,
-			"Required Tool %s is not installed in the system\n",
-			tool,
-		)
-		return false
-	} else {
-		color.Printf(color.InfoColor, "Required Tool %s is installed at %s\n", tool, str)
-		return true
+// builtinTools returns the tools always required by go-ci-tool:
+// Go with the minimum supported version and the pinned golangci-lint
+func builtinTools(golangCILintMirror string) []ToolSpec {
+	goTool := ToolSpec{
+		Name:         GO,
+		VersionArgs:  []string{"version"},
+		VersionRegex: `go([0-9]+\.[0-9]+(?:\.[0-9]+)?)`,
 	}
-}
-
-func checkGoInstalled() (_ bool, err error) {
-	minSupportedGoVersion := constants.MinSupportedGoVersion()
 
-	isInstalled := checkIfInstalled(GO)
-
-	if !isInstalled {
-		return false, nil
+	if minGoVersion := constants.MinSupportedGoVersion(); minGoVersion != "" {
+		goTool.Constraint = ">=" + minGoVersion
 	}
 
-	goVersionString, err := exec.Command(GO, "version").Output()
-	if err != nil {
-		err = fmt.Errorf("error while checking go version: %s", err.Error())
-		return
+	lintTool := ToolSpec{
+		Name:        GoLangCILint,
+		VersionArgs: []string{"version", "--short"},
 	}
 
-	if !utf8.Valid(goVersionString) {
-		err = fmt.Errorf(
-			"got invalid utf8 string while checking go version: %q",
-			goVersionString,
-		)
-		return
+	if lintVersion := constants.GolangCILintVersion(); lintVersion != "" {
+		lintTool.Constraint = lintVersion
+		lintTool.Install = &InstallSpec{
+			Method:  InstallMethodArch,
+			Version: lintVersion,
+			URL:     golangCILintMirror,
+			Archive: GoLangCILint + "-{{.Version}}-{{.OS}}-" +
+				`{{if eq .Arch "arm"}}armv6{{else}}{{.Arch}}{{end}}{{.ArchiveExt}}`,
+			Checksums: GoLangCILint + "-{{.Version}}-checksums.txt",
+			BinaryPath: GoLangCILint + "-{{.Version}}-{{.OS}}-" +
+				`{{if eq .Arch "arm"}}armv6{{else}}{{.Arch}}{{end}}/` +
+				GoLangCILint + "{{.Exe}}",
+		}
 	}
 
-	goVersion := strings.Replace(
-		strings.Split(string(goVersionString), " ")[2],
-		GO,
-		"",
-		1,
-	)
+	return []ToolSpec{goTool, lintTool}
+}
 
-	if !semver.IsValid("v" + goVersion) {
-		err = fmt.Errorf(
-			"got invalid version string while checking go version: %q",
-			goVersionString,
-		)
-		return
-	}
+// mergeTools adds the manifest tools to the built-in tools,
+// a manifest entry replaces the built-in tool with the same name
+func mergeTools(builtin []ToolSpec, manifest ToolManifest) []ToolSpec {
+	tools := make([]ToolSpec, 0, len(builtin)+len(manifest.Tools))
 
-	if semver.Compare(goVersion, minSupportedGoVersion) < 0 {
-		color.Printf(
-			color.ErrorColorBold,
-			"Installed Go Version %s is lower than required version %s. Upgrade your go installation.\n",
-			goVersion,
-			minSupportedGoVersion,
-		)
-		return false, nil
+	for _, tool := range builtin {
+		for _, override := range manifest.Tools {
+			if override.Name == tool.Name {
+				tool = override
+				break
+			}
+		}
+
+		tools = append(tools, tool)
 	}
 
-	color.Printf(
-		color.InfoColor,
-		"Minimum Supported Go Version: %s\n",
-		minSupportedGoVersion,
-	)
-	color.Printf(color.InfoColor, "Installed Go Version: %s\n", goVersion)
+	for _, tool := range manifest.Tools {
+		isBuiltin := false
 
-	color.Printf(color.SuccessColorBold, "Go is installed :)\n")
-	return true, nil
-}
+		for _, b := range builtin {
+			if b.Name == tool.Name {
+				isBuiltin = true
+				break
+			}
+		}
 
-func checkGoCILintInstalled() (_ bool, err error) {
-	golangCILintVersion := constants.GolangCILintVersion()
+		if !isBuiltin {
+			tools = append(tools, tool)
+		}
+	}
 
-	isInstalled := checkIfInstalled(GoLangCILint)
+	return tools
+}
 
-	if !isInstalled {
-		return false, nil
+// checkTool finds the tool in PATH and verifies its version
+func checkTool(tool ToolSpec) toolResult {
+	result := toolResult{tool: tool, status: statusMissing}
+
+	path, err := exec.LookPath(tool.binary())
+	if err != nil {
+		return result
 	}
 
-	versionString, err := exec.Command(GoLangCILint, "version", "--short").Output()
+	result.path = path
+
+	//nolint:gosec // Tool and version arguments come from the tool manifest
+	output, err := exec.Command(path, tool.VersionArgs...).CombinedOutput()
 	if err != nil {
-		err = fmt.Errorf("error while checking golangci-lint version: %s", err.Error())
-		return
+		result.status = statusUnknown
+		result.reason = fmt.Sprintf("error while checking version: %s", err.Error())
+		return result
+	}
+
+	if !utf8.Valid(output) {
+		result.status = statusUnknown
+		result.reason = fmt.Sprintf("got invalid utf8 string while checking version: %q", output)
+		return result
 	}
 
-	if !utf8.Valid(versionString) {
-		err = fmt.Errorf(
-			"got invalid utf8 string while checking golangci-lint version: %q",
-			versionString,
-		)
-		return
+	version, err := tool.parseVersion(string(output))
+	if err != nil {
+		result.status = statusUnknown
+		result.reason = err.Error()
+		return result
 	}
 
-	version := strings.Trim(string(versionString), " \n")
+	result.found = version
 
-	if !semver.IsValid("v" + version) {
-		err = fmt.Errorf(
-			"got invalid version string while checking golangci-lint version: %q",
-			version,
-		)
-		return
+	ok, err := satisfiesConstraint(version, tool.Constraint)
+	if err != nil {
+		result.status = statusUnknown
+		result.reason = err.Error()
+		return result
 	}
 
-	cmpResult := semver.Compare(version, golangCILintVersion)
-	var action string
+	if ok {
+		result.status = statusOK
+	} else {
+		result.status = statusMismatch
+	}
+
+	return result
+}
 
-	if cmpResult < 0 {
-		action = "Upgrade"
-	} else if cmpResult > 0 {
-		action = "Downgrade"
+func orDash(value string) string {
+	if value == "" {
+		return "-"
 	}
+	return value
+}
+
+func printToolTable(results []toolResult) {
+	rows := make([]color.TableRow, 0, len(results))
+
+	for _, r := range results {
+		row := color.TableRow{
+			Color: color.SuccessColor,
+			Cells: []string{r.tool.Name, orDash(r.found), orDash(r.tool.Constraint), string(r.status), orDash(r.path)},
+		}
+
+		if r.status != statusOK {
+			row.Color = color.ErrorColorBold
+		}
 
-	if cmpResult != 0 {
-		color.Printf(
-			color.ErrorColorBold,
-			"Installed golangci-lint version %s. We require it to be %s. %s your golangci-lint installation.\n",
-			version,
-			golangCILintVersion,
-			action,
-		)
-		return
+		rows = append(rows, row)
 	}
 
-	color.Printf(
-		color.SuccessColorBold,
-		"GoLang CI Lint v%s is installed :)\n",
-		golangCILintVersion,
-	)
-	return true, nil
+	color.PrintTable([]string{"TOOL", "FOUND", "REQUIRED", "STATUS", "PATH"}, rows)
+}
+
+// printToolHints explains how to fix the tools that failed the check
+func printToolHints(results []toolResult) {
+	for _, r := range results {
+		if r.status == statusOK {
+			continue
+		}
+
+		var hint string
+
+		switch {
+		case r.status == statusUnknown:
+			hint = r.reason
+		case r.tool.Install != nil:
+			hint = "run with --install to install the required version"
+		case r.tool.Name == GO:
+			hint = "upgrade your go installation (https://go.dev/doc/install)"
+		default:
+			hint = "install a version matching " + orDash(r.tool.Constraint)
+		}
+
+		color.Printf(color.WarningColor, "%s: %s\n", r.tool.Name, hint)
+	}
 }
 
 func GetCheckInstallationCommand() *cobra.Command {
+	const (
+		InstallFlag   = "install"
+		MirrorFlag    = "mirror"
+		ToolCacheFlag = "tool-cache"
+		ManifestFlag  = "manifest"
+	)
+
 	checkInstallationCommand := &cobra.Command{
 		Use: "check-tools",
-		RunE: func(_ *cobra.Command, _ []string) error {
-			isGoInstalled, err := checkGoInstalled()
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			install, err := cmd.Flags().GetBool(InstallFlag)
 			if err != nil {
 				return err
 			}
 
-			color.Println(color.NoColor)
+			mirror, err := cmd.Flags().GetString(MirrorFlag)
+			if err != nil {
+				return err
+			}
+
+			toolCache, err := cmd.Flags().GetString(ToolCacheFlag)
+			if err != nil {
+				return err
+			}
+
+			manifestPath, err := cmd.Flags().GetString(ManifestFlag)
+			if err != nil {
+				return err
+			}
 
-			isLintToolInstalled, err := checkGoCILintInstalled()
+			manifest, err := LoadToolManifest(
+				manifestPath,
+				cmd.Flags().Changed(ManifestFlag),
+			)
 			if err != nil {
 				return err
 			}
 
-			if !isGoInstalled || !isLintToolInstalled {
+			tools := mergeTools(builtinTools(mirror), manifest)
+			results := make([]toolResult, 0, len(tools))
+
+			for _, tool := range tools {
+				result := checkTool(tool)
+
+				if install && tool.Install != nil &&
+					(result.status == statusMissing || result.status == statusMismatch) {
+					err = installTool(tool, toolCache)
+					if err != nil {
+						color.Printf(color.ErrorColor, "Unable to install %s: %s\n", tool.Name, err.Error())
+					} else {
+						result = checkTool(tool)
+					}
+
+					color.Println(color.NoColor)
+				}
+
+				results = append(results, result)
+			}
+
+			printToolTable(results)
+
+			allOK := true
+
+			for _, r := range results {
+				if r.status != statusOK {
+					allOK = false
+				}
+			}
+
+			if !allOK {
+				color.Println(color.NoColor)
+				printToolHints(results)
+
 				return customerrors.NewErrNoLog()
-			} else {
-				return nil
 			}
+
+			color.Printf(color.SuccessColorBold, "\nAll required tools are installed :)\n")
+
+			return nil
 		},
 		Args: cobra.NoArgs,
 		CompletionOptions: cobra.CompletionOptions{
 			DisableDefaultCmd: true,
 		},
 		Short:                 "Check if required tools are installed in the system",
-		Long:                  "Checks if the required tools are installed in the system with correct versions. Currently Checks for Go (https://go.dev/) and golangci-lint (https://golangci-lint.run/).",
+		Long:                  "Checks if the required tools are installed in the system with correct versions. Always checks for Go (https://go.dev/) and golangci-lint (https://golangci-lint.run/), other tools are read from the tool manifest (" + DefaultManifestFile + " by default). Optionally installs the tools that have an install method.",
 		SilenceErrors:         true,
 		SilenceUsage:          true,
 		DisableFlagsInUseLine: true,
 	}
 
+	defaultMirror := os.Getenv(GolangCILintMirrorEnv)
+	if defaultMirror == "" {
+		defaultMirror = DefaultGolangCILintMirror
+	}
+
+	checkInstallationCommand.Flags().
+		Bool(InstallFlag, false, "Install the tools that are missing or have a different version, if they have an install method")
+	checkInstallationCommand.Flags().
+		String(MirrorFlag, defaultMirror, "URL or local directory with golangci-lint release archives and checksums. Defaults to $"+GolangCILintMirrorEnv+" if set")
+	checkInstallationCommand.Flags().
+		String(ToolCacheFlag, defaultToolCacheDir(), "Directory where downloaded tools are cached by version")
+	checkInstallationCommand.Flags().
+		String(ManifestFlag, DefaultManifestFile, "JSON tool manifest listing additional tools, their version constraints and install methods")
+
 	return checkInstallationCommand
 }
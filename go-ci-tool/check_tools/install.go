@@ -0,0 +1,493 @@
+package checktools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+)
+
+// Release archive of a tool and how to find the binary inside it
+type archiveSpec struct {
+	// Name of the tool, used for tool cache directory
+	tool    string
+	version string
+	// Archive file name
+	archiveName string
+	// File listing SHA256 checksums of the release archives
+	checksumsName string
+	// Slash separated path of the binary inside the archive
+	binaryPath string
+	// Name of the binary installed in GOBIN
+	binaryName string
+}
+
+const (
+	DefaultGolangCILintMirror = "https://github.com/golangci/golangci-lint/releases/download"
+	GolangCILintMirrorEnv     = "GOLANGCI_LINT_MIRROR"
+	RunnerToolCacheEnv        = "RUNNER_TOOL_CACHE"
+
+	downloadTimeout   = 10 * time.Minute
+	toolCacheDirPerm  = fs.FileMode(0o755)
+	executablePerm    = fs.FileMode(0o755)
+	completeMarkerExt = ".complete"
+	windowsOS         = "windows"
+	goWorkOff         = "GOWORK=off"
+)
+
+func executableName(name string) string {
+	if runtime.GOOS == windowsOS {
+		return name + ".exe"
+	}
+	return name
+}
+
+// archiveSpecFor expands the archive install templates of the tool
+// for the current platform
+func archiveSpecFor(tool ToolSpec) (archiveSpec, error) {
+	install := tool.Install
+
+	data := templateData{
+		Version:    strings.TrimPrefix(install.Version, "v"),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		ArchiveExt: ".tar.gz",
+		Exe:        "",
+	}
+
+	if runtime.GOOS == windowsOS {
+		data.ArchiveExt = ".zip"
+		data.Exe = ".exe"
+	}
+
+	spec := archiveSpec{
+		tool:       tool.Name,
+		version:    data.Version,
+		binaryName: executableName(tool.binary()),
+	}
+
+	var err error
+
+	spec.archiveName, err = expandTemplate("archive", install.Archive, data)
+	if err != nil {
+		return spec, fmt.Errorf("invalid archive template for %s: %s", tool.Name, err.Error())
+	}
+
+	spec.checksumsName, err = expandTemplate("checksums", install.Checksums, data)
+	if err != nil {
+		return spec, fmt.Errorf("invalid checksums template for %s: %s", tool.Name, err.Error())
+	}
+
+	spec.binaryPath, err = expandTemplate("binary_path", install.BinaryPath, data)
+	if err != nil {
+		return spec, fmt.Errorf("invalid binary_path template for %s: %s", tool.Name, err.Error())
+	}
+
+	return spec, nil
+}
+
+// isURL reports whether the mirror is a http(s) URL instead of a local directory
+func isURL(mirror string) bool {
+	u, err := url.Parse(mirror)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+func downloadFile(fileURL string, dst io.Writer) error {
+	client := http.Client{Timeout: downloadTimeout}
+
+	//nolint:noctx // Client has a timeout
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck // Read only body
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got status %s while downloading %s", resp.Status, fileURL)
+	}
+
+	_, err = io.Copy(dst, resp.Body)
+
+	return err
+}
+
+// fetchReleaseFile copies a release file from the mirror to `dst`.
+// For a URL mirror files are fetched from <mirror>/v<version>/<name>.
+// For a local directory <dir>/v<version>/<name> is used if present,
+// otherwise <dir>/<name>.
+func fetchReleaseFile(mirror, version, name string, dst io.Writer) error {
+	if isURL(mirror) {
+		fileURL := strings.TrimSuffix(mirror, "/") + "/" + path.Join("v"+version, name)
+		color.Printf(color.MutedColor, "Downloading %s\n", fileURL)
+
+		return downloadFile(fileURL, dst)
+	}
+
+	candidates := []string{
+		filepath.Join(mirror, "v"+version, name),
+		filepath.Join(mirror, name),
+	}
+
+	for _, candidate := range candidates {
+		//nolint:gosec // Local mirror directory provided by the user
+		f, err := os.Open(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		color.Printf(color.MutedColor, "Copying %s\n", candidate)
+
+		_, err = io.Copy(dst, f)
+
+		return errors.Join(err, f.Close())
+	}
+
+	return fmt.Errorf("%s not found in local mirror %s", name, mirror)
+}
+
+// expectedChecksum finds the checksum of the archive in the checksums file
+func expectedChecksum(checksums string, archiveName string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(checksums))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		//nolint:mnd // Lines are of the form "<sha256> <file>"
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == archiveName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("checksum for %s not found", archiveName)
+}
+
+func extractFromTarGz(archivePath, binaryPath string, dst io.Writer) error {
+	//nolint:gosec // Archive downloaded to a temporary file
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Read only file
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeReg && header.Name == binaryPath {
+			_, err = io.CopyN(dst, tr, header.Size)
+			return err
+		}
+	}
+
+	return fmt.Errorf("%s not found in archive", binaryPath)
+}
+
+func extractFromZip(archivePath, binaryPath string, dst io.Writer) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close() //nolint:errcheck // Read only file
+
+	for _, f := range r.File {
+		if f.Name != binaryPath {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		//nolint:gosec // Size of a verified release archive
+		_, err = io.Copy(dst, rc)
+
+		return errors.Join(err, rc.Close())
+	}
+
+	return fmt.Errorf("%s not found in archive", binaryPath)
+}
+
+// defaultToolCacheDir returns the runner tool cache in GitHub Actions,
+// or the user cache directory otherwise
+func defaultToolCacheDir() string {
+	if dir := os.Getenv(RunnerToolCacheEnv); dir != "" {
+		return dir
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "go-ci-tool", "tools")
+	}
+
+	return filepath.Join(userCacheDir, "go-ci-tool", "tools")
+}
+
+// installArchiveToToolCache downloads, verifies and extracts the tool binary
+// into <toolCache>/<tool>/<version>/<arch>, unless already present.
+// Returns the path of the binary in the tool cache.
+func installArchiveToToolCache(
+	spec archiveSpec,
+	mirror string,
+	toolCache string,
+) (string, error) {
+	versionDir := filepath.Join(toolCache, spec.tool, spec.version, runtime.GOARCH)
+	binaryPath := filepath.Join(versionDir, spec.binaryName)
+	completeMarker := versionDir + completeMarkerExt
+
+	if _, err := os.Stat(completeMarker); err == nil {
+		if _, err := os.Stat(binaryPath); err == nil {
+			color.Printf(color.InfoColor, "Found %s in tool cache at %s\n", spec.tool, versionDir)
+			return binaryPath, nil
+		}
+	}
+
+	checksums := strings.Builder{}
+
+	err := fetchReleaseFile(mirror, spec.version, spec.checksumsName, &checksums)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch checksums for %s: %s", spec.tool, err.Error())
+	}
+
+	expected, err := expectedChecksum(checksums.String(), spec.archiveName)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := os.CreateTemp("", "go-ci-tool-archive-*")
+	if err != nil {
+		return "", err
+	}
+	//nolint:errcheck // Best effort cleanup of temporary file
+	defer os.Remove(archive.Name())
+
+	h := sha256.New()
+	err = errors.Join(
+		fetchReleaseFile(mirror, spec.version, spec.archiveName, io.MultiWriter(archive, h)),
+		archive.Close(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch %s: %s", spec.archiveName, err.Error())
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != expected {
+		return "", fmt.Errorf(
+			"checksum mismatch for %s, expected %s, got %s",
+			spec.archiveName,
+			expected,
+			actual,
+		)
+	}
+
+	color.Printf(color.MutedColor, "Verified checksum of %s\n", spec.archiveName)
+
+	err = os.MkdirAll(versionDir, toolCacheDirPerm)
+	if err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // Binary in the tool cache needs to be executable
+	out, err := os.OpenFile(binaryPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, executablePerm)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(spec.archiveName, ".zip") {
+		err = extractFromZip(archive.Name(), spec.binaryPath, out)
+	} else {
+		err = extractFromTarGz(archive.Name(), spec.binaryPath, out)
+	}
+
+	err = errors.Join(err, out.Close())
+	if err != nil {
+		return "", fmt.Errorf("unable to extract %s: %s", spec.archiveName, err.Error())
+	}
+
+	marker, err := os.Create(completeMarker)
+	if err != nil {
+		return "", err
+	}
+
+	err = marker.Close()
+	if err != nil {
+		return "", err
+	}
+
+	color.Printf(color.InfoColor, "Added %s v%s to tool cache at %s\n", spec.tool, spec.version, versionDir)
+
+	return binaryPath, nil
+}
+
+// goBinDir returns GOBIN, or GOPATH/bin if GOBIN is not set
+func goBinDir() (string, error) {
+	out, err := exec.Command(GO, "env", "GOBIN", "GOPATH").Output()
+	if err != nil {
+		return "", fmt.Errorf("error while fetching GOBIN value: %s", err.Error())
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n")
+
+	if goBin := strings.TrimSpace(lines[0]); goBin != "" {
+		return goBin, nil
+	}
+
+	//nolint:mnd // Second line is GOPATH
+	if len(lines) < 2 || strings.TrimSpace(lines[1]) == "" {
+		return "", errors.New("unable to find GOBIN or GOPATH")
+	}
+
+	// GOPATH can be a list, binaries are installed in the first entry
+	goPath := filepath.SplitList(strings.TrimSpace(lines[1]))[0]
+
+	return filepath.Join(goPath, "bin"), nil
+}
+
+func copyExecutable(src, dst string) error {
+	//nolint:gosec // Binary in the tool cache
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck // Read only file
+
+	//nolint:gosec // Binary in GOBIN needs to be executable
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, executablePerm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+
+	return errors.Join(err, out.Close())
+}
+
+// linkIntoGoBin makes the binary available in GOBIN. A symlink is used
+// where possible, otherwise (e.g. Windows without privileges) it is copied.
+func linkIntoGoBin(binaryPath, binaryName string) error {
+	goBin, err := goBinDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(goBin, toolCacheDirPerm)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(goBin, binaryName)
+
+	err = os.Remove(target)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove existing %s: %s", target, err.Error())
+	}
+
+	err = os.Symlink(binaryPath, target)
+	if err != nil {
+		err = copyExecutable(binaryPath, target)
+		if err != nil {
+			return fmt.Errorf("unable to install %s to %s: %s", binaryName, goBin, err.Error())
+		}
+
+		color.Printf(color.InfoColor, "Copied %s to %s\n", binaryPath, target)
+
+		return nil
+	}
+
+	color.Printf(color.InfoColor, "Linked %s to %s\n", target, binaryPath)
+
+	return nil
+}
+
+// installWithGo runs `go install <package>@<version>`
+func installWithGo(tool ToolSpec) error {
+	target := tool.Install.Package + "@v" + strings.TrimPrefix(tool.Install.Version, "v")
+
+	buf := bytes.Buffer{}
+
+	cmd := exec.Command(GO, "install", target)
+	cmd.Env = append(os.Environ(), goWorkOff)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+
+	err := cmd.Run()
+
+	if cmd.ProcessState == nil {
+		return fmt.Errorf("error while running 'go install %s', error: %s", target, err.Error())
+	}
+
+	color.Print(color.MutedColor, buf.String())
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		return fmt.Errorf("unable to install %s with 'go install %s'", tool.Name, target)
+	}
+
+	color.Printf(color.InfoColor, "Installed %s with 'go install %s'\n", tool.Name, target)
+
+	return nil
+}
+
+// installTool installs the tool using its install method. Archives are
+// cached in the tool cache and linked into GOBIN.
+func installTool(tool ToolSpec, toolCache string) error {
+	if tool.Install == nil {
+		return fmt.Errorf("no install method known for %s", tool.Name)
+	}
+
+	color.Printf(
+		color.InfoColorBold,
+		"Installing %s v%s\n",
+		tool.Name,
+		strings.TrimPrefix(tool.Install.Version, "v"),
+	)
+
+	if tool.Install.Method == InstallMethodGo {
+		return installWithGo(tool)
+	}
+
+	spec, err := archiveSpecFor(tool)
+	if err != nil {
+		return err
+	}
+
+	binaryPath, err := installArchiveToToolCache(spec, tool.Install.URL, toolCache)
+	if err != nil {
+		return err
+	}
+
+	return linkIntoGoBin(binaryPath, spec.binaryName)
+}
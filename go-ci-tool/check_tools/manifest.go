@@ -0,0 +1,258 @@
+package checktools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"golang.org/x/mod/semver"
+)
+
+// ToolManifest lists the tools required by the repository
+type ToolManifest struct {
+	Tools []ToolSpec `json:"tools"`
+}
+
+// ToolSpec describes how to find, verify and install a tool
+type ToolSpec struct {
+	Name string `json:"name"`
+	// Binary name to look for in PATH, defaults to name
+	Binary string `json:"binary,omitempty"`
+	// Arguments to make the binary print its version
+	VersionArgs []string `json:"version_args"`
+	// Regular expression with one capture group for the version in the
+	// output. Defaults to the first version like string.
+	VersionRegex string `json:"version_regex,omitempty"`
+	// Comma separated version constraints like "1.2.3", ">=1.2.0,<2.0.0"
+	Constraint string       `json:"constraint"`
+	Install    *InstallSpec `json:"install,omitempty"`
+}
+
+// InstallSpec describes how to install a tool
+type InstallSpec struct {
+	// "go" for `go install <package>@<version>`, "archive" for release archives
+	Method  string `json:"method"`
+	Version string `json:"version"`
+	// Package to install for the "go" method
+	Package string `json:"package,omitempty"`
+	// Base URL or local directory of release files for the "archive" method,
+	// files are looked up at <url>/v<version>/<name>
+	URL string `json:"url,omitempty"`
+	// Templates for the names of the archive, checksums file and the binary
+	// path inside the archive. Available fields are .Version, .OS, .Arch,
+	// .ArchiveExt (.tar.gz or .zip) and .Exe (.exe on Windows)
+	Archive    string `json:"archive,omitempty"`
+	Checksums  string `json:"checksums,omitempty"`
+	BinaryPath string `json:"binary_path,omitempty"`
+}
+
+type templateData struct {
+	Version    string
+	OS         string
+	Arch       string
+	ArchiveExt string
+	Exe        string
+}
+
+type versionClause struct {
+	op      string
+	version string
+}
+
+const (
+	DefaultManifestFile = ".go-ci-tools.json"
+	InstallMethodGo     = "go"
+	InstallMethodArch   = "archive"
+)
+
+//nolint:gochecknoglobals // Compiled once, used as default version pattern
+var defaultVersionRegex = regexp.MustCompile(`v?([0-9]+\.[0-9]+(?:\.[0-9]+)?)`)
+
+func (t ToolSpec) binary() string {
+	if t.Binary != "" {
+		return t.Binary
+	}
+	return t.Name
+}
+
+// LoadToolManifest reads the manifest file.
+// Returns an empty manifest if the file doesn't exist and is not required.
+func LoadToolManifest(path string, required bool) (ToolManifest, error) {
+	manifest := ToolManifest{}
+
+	//nolint:gosec // Manifest path provided by the user
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return manifest, nil
+		}
+		return manifest, fmt.Errorf("unable to read tool manifest %s: %s", path, err.Error())
+	}
+
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("unable to parse tool manifest %s: %s", path, err.Error())
+	}
+
+	for _, tool := range manifest.Tools {
+		err := tool.validate()
+		if err != nil {
+			return manifest, fmt.Errorf("invalid tool %q in manifest %s: %s", tool.Name, path, err.Error())
+		}
+	}
+
+	return manifest, nil
+}
+
+func (t ToolSpec) validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if t.VersionRegex != "" {
+		re, err := regexp.Compile(t.VersionRegex)
+		if err != nil {
+			return fmt.Errorf("invalid version_regex: %s", err.Error())
+		}
+
+		if re.NumSubexp() != 1 {
+			return errors.New("version_regex must have exactly one capture group")
+		}
+	}
+
+	_, err := parseConstraint(t.Constraint)
+	if err != nil {
+		return err
+	}
+
+	if t.Install == nil {
+		return nil
+	}
+
+	switch t.Install.Method {
+	case InstallMethodGo:
+		if t.Install.Package == "" {
+			return errors.New("install.package is required for 'go' install method")
+		}
+	case InstallMethodArch:
+		if t.Install.URL == "" || t.Install.Archive == "" ||
+			t.Install.Checksums == "" || t.Install.BinaryPath == "" {
+			return errors.New(
+				"install.url, install.archive, install.checksums and " +
+					"install.binary_path are required for 'archive' install method",
+			)
+		}
+	default:
+		return fmt.Errorf("unknown install method %q", t.Install.Method)
+	}
+
+	if t.Install.Version == "" {
+		return errors.New("install.version is required")
+	}
+
+	return nil
+}
+
+// parseVersion extracts the version from the output of version command
+func (t ToolSpec) parseVersion(output string) (string, error) {
+	re := defaultVersionRegex
+	if t.VersionRegex != "" {
+		re = regexp.MustCompile(t.VersionRegex)
+	}
+
+	match := re.FindStringSubmatch(output)
+	//nolint:mnd // Full match and the capture group
+	if len(match) != 2 || !semver.IsValid("v"+match[1]) {
+		return "", fmt.Errorf("unable to find version of %s in %q", t.Name, strings.TrimSpace(output))
+	}
+
+	return match[1], nil
+}
+
+// parseConstraint parses comma separated clauses, each an optional
+// operator (=, >=, >, <=, <) followed by a version
+func parseConstraint(constraint string) ([]versionClause, error) {
+	clauses := make([]versionClause, 0)
+
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+
+		version := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(part, op)), "v")
+		if !semver.IsValid("v" + version) {
+			return nil, fmt.Errorf("invalid version %q in constraint %q", version, constraint)
+		}
+
+		if op == "" {
+			op = "="
+		}
+
+		clauses = append(clauses, versionClause{op: op, version: version})
+	}
+
+	return clauses, nil
+}
+
+// satisfiesConstraint reports whether version satisfies all the clauses
+func satisfiesConstraint(version string, constraint string) (bool, error) {
+	clauses, err := parseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range clauses {
+		cmp := semver.Compare("v"+version, "v"+c.version)
+
+		var ok bool
+
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		default:
+			ok = cmp == 0
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func expandTemplate(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,159 @@
+// Package cioutput prints key value output in formats consumed by CI systems
+package cioutput
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+)
+
+type Format int
+
+type Field struct {
+	// Key used in JSON and GitHub output formats
+	Key string
+	// Variable name used in text, env and shell formats
+	EnvName string
+	// Either a string or a []string
+	Value any
+}
+
+const (
+	// Human readable `NAME:value` lines
+	FormatText Format = iota
+	// Single JSON object
+	FormatJSON
+	// `NAME=value` lines, as used by .env files and $GITHUB_ENV
+	FormatEnv
+	// `key=value` lines, as used by $GITHUB_OUTPUT
+	FormatGitHubOutput
+	// POSIX shell `export NAME='value'` lines
+	FormatShell
+)
+
+const delimiterRandomBytes = 8
+
+// stringValue returns the value as string, lists are joined with new lines
+func (f Field) stringValue() string {
+	switch v := f.Value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, "\n")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeKeyValue writes `name=value`, or uses the multi-line delimiter syntax
+// supported by $GITHUB_ENV and $GITHUB_OUTPUT if value spans multiple lines
+func writeKeyValue(buf *bytes.Buffer, name, value string) error {
+	if !strings.ContainsAny(value, "\r\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return nil
+	}
+
+	random := make([]byte, delimiterRandomBytes)
+
+	_, err := rand.Read(random)
+	if err != nil {
+		return err
+	}
+
+	delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+	fmt.Fprintf(buf, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+
+	return nil
+}
+
+// shellQuote quotes the value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func encodeJSON(buf *bytes.Buffer, fields []Field) error {
+	// Encode field by field to keep the order of fields
+	buf.WriteByte('{')
+
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(key)
+		buf.WriteString(": ")
+		buf.Write(value)
+	}
+
+	buf.WriteString("}\n")
+
+	return nil
+}
+
+// Print writes the fields to stdout in the given format
+func Print(format Format, fields []Field) error {
+	if format == FormatText {
+		for _, f := range fields {
+			color.Printf(color.InfoColor, "%s:%s\n", f.EnvName, f.stringValue())
+		}
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+
+	var err error
+
+	switch format {
+	case FormatJSON:
+		err = encodeJSON(&buf, fields)
+	case FormatEnv:
+		for _, f := range fields {
+			err = writeKeyValue(&buf, f.EnvName, f.stringValue())
+			if err != nil {
+				break
+			}
+		}
+	case FormatGitHubOutput:
+		for _, f := range fields {
+			err = writeKeyValue(&buf, f.Key, f.stringValue())
+			if err != nil {
+				break
+			}
+		}
+	case FormatShell:
+		for _, f := range fields {
+			fmt.Fprintf(&buf, "export %s=%s\n", f.EnvName, shellQuote(f.stringValue()))
+		}
+	case FormatText:
+		// Handled above
+	default:
+		err = fmt.Errorf("unknown output format %d", format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error while formatting output: %s", err.Error())
+	}
+
+	_, err = os.Stdout.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error while writing output: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package color
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fatih/color"
+)
+
+// TableRow is a row of a table printed in a single color
+type TableRow struct {
+	Color *color.Color
+	Cells []string
+}
+
+const tableColumnPadding = 3
+
+// PrintTable prints the rows with aligned columns, the header in HighLightColor
+// and every row in its own color
+func PrintTable(header []string, rows []TableRow) {
+	buf := bytes.Buffer{}
+	w := tabwriter.NewWriter(&buf, 0, 0, tableColumnPadding, ' ', 0)
+
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, r := range rows {
+		fmt.Fprintln(w, strings.Join(r.Cells, "\t"))
+	}
+
+	//nolint:errcheck // Writes to an in-memory buffer
+	w.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	Println(HighLightColor, lines[0])
+
+	for i, r := range rows {
+		Println(r.Color, lines[i+1])
+	}
+}
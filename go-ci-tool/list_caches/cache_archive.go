@@ -0,0 +1,543 @@
+package listcaches
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"github.com/spf13/cobra"
+)
+
+// Manifest stored for each saved cache key
+type cacheManifest struct {
+	Key     string    `json:"key"`
+	Created time.Time `json:"created"`
+	// Archive hash for each cache, keyed by cache name
+	Archives map[string]string `json:"archives"`
+}
+
+type namedCache struct {
+	name string
+	path string
+}
+
+const (
+	archiveBlobsDir  = "blobs"
+	archiveKeysDir   = "keys"
+	archiveExt       = ".tar.gz"
+	manifestExt      = ".json"
+	storeDirPerm     = fs.FileMode(0o755)
+	restoredDirPerm  = fs.FileMode(0o755)
+	archiveFilesPerm = fs.FileMode(0o644)
+)
+
+func (c cachePaths) named() []namedCache {
+	return []namedCache{
+		{"GOCACHE", c.GoCache},
+		{"GOLANGCI_LINT_CACHE", c.GoLangCILintCache},
+		{"GOMODCACHE", c.GoModCache},
+	}
+}
+
+// writeCacheArchive writes a gzip compressed tar of the directory to `w`.
+// Entries are written in lexical order without timestamps or ownership,
+// so the same directory content always produces the same archive.
+func writeCacheArchive(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Cache directory doesn't exist yet, archive is empty
+			if path == dir && errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if path == dir || (!d.IsDir() && !d.Type().IsRegular()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:   filepath.ToSlash(rel),
+			Mode:   int64(info.Mode().Perm()),
+			Format: tar.FormatPAX,
+		}
+
+		if d.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = info.Size()
+		}
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		//nolint:gosec // File inside the cache directory
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.CopyN(tw, f, info.Size())
+
+		return errors.Join(err, f.Close())
+	})
+
+	return errors.Join(err, tw.Close(), gz.Close())
+}
+
+// writeFileAtomic writes the content produced by `write` to a temporary file
+// and renames it to `path` once complete
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	err = errors.Join(write(tmp), tmp.Close())
+	if err == nil {
+		err = os.Chmod(tmp.Name(), archiveFilesPerm)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		//nolint:errcheck,gosec // Best effort cleanup of temporary file
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// saveCacheBlob archives the cache directory into the store and
+// returns the hash of the archive which is also its name
+func saveCacheBlob(storeDir string, cache namedCache) (string, error) {
+	blobsDir := filepath.Join(storeDir, archiveBlobsDir)
+
+	tmp, err := os.CreateTemp(blobsDir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	err = errors.Join(
+		writeCacheArchive(cache.path, io.MultiWriter(tmp, h)),
+		tmp.Close(),
+	)
+
+	if err != nil {
+		//nolint:errcheck,gosec // Best effort cleanup of temporary file
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	blobPath := filepath.Join(blobsDir, sum+archiveExt)
+
+	// Same content is already stored
+	if _, err := os.Stat(blobPath); err == nil {
+		color.Printf(color.MutedColor, "%s: archive %s already present\n", cache.name, sum)
+		return sum, os.Remove(tmp.Name())
+	}
+
+	err = os.Chmod(tmp.Name(), archiveFilesPerm)
+	if err == nil {
+		err = os.Rename(tmp.Name(), blobPath)
+	}
+
+	if err != nil {
+		//nolint:errcheck,gosec // Best effort cleanup of temporary file
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	color.Printf(color.MutedColor, "%s: saved archive %s\n", cache.name, sum)
+
+	return sum, nil
+}
+
+func manifestPath(storeDir, key string) string {
+	return filepath.Join(storeDir, archiveKeysDir, key+manifestExt)
+}
+
+func readManifest(path string) (cacheManifest, error) {
+	manifest := cacheManifest{}
+
+	//nolint:gosec // Manifest inside the cache store
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+
+	err = json.Unmarshal(content, &manifest)
+
+	return manifest, err
+}
+
+func saveCaches(storeDir string, keys CacheKeys, cache cachePaths) error {
+	for _, d := range []string{archiveBlobsDir, archiveKeysDir} {
+		err := os.MkdirAll(filepath.Join(storeDir, d), storeDirPerm)
+		if err != nil {
+			return fmt.Errorf("unable to create cache store at %s: %s", storeDir, err.Error())
+		}
+	}
+
+	if _, err := os.Stat(manifestPath(storeDir, keys.Key)); err == nil {
+		color.Printf(color.SuccessColorBold, "Cache already saved for key: %s\n", keys.Key)
+		return nil
+	}
+
+	manifest := cacheManifest{
+		Key:      keys.Key,
+		Created:  time.Now().UTC(),
+		Archives: make(map[string]string),
+	}
+
+	for _, c := range cache.named() {
+		sum, err := saveCacheBlob(storeDir, c)
+		if err != nil {
+			return fmt.Errorf("unable to archive %s at %s: %s", c.name, c.path, err.Error())
+		}
+
+		manifest.Archives[c.name] = sum
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error while formatting cache manifest to JSON: %s", err.Error())
+	}
+
+	err = writeFileAtomic(manifestPath(storeDir, keys.Key), func(w io.Writer) error {
+		_, err := w.Write(append(content, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write cache manifest: %s", err.Error())
+	}
+
+	color.Printf(color.SuccessColorBold, "Saved cache for key: %s\n", keys.Key)
+
+	return nil
+}
+
+// findManifest returns the manifest for the exact key if present.
+// Otherwise returns the most recently created manifest whose key starts
+// with the first matching restore key.
+func findManifest(storeDir string, keys CacheKeys) (cacheManifest, bool, error) {
+	manifest, err := readManifest(manifestPath(storeDir, keys.Key))
+	if err == nil {
+		return manifest, true, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return manifest, false, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(storeDir, archiveKeysDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cacheManifest{}, false, nil
+		}
+		return cacheManifest{}, false, err
+	}
+
+	for _, restoreKey := range keys.RestoreKeys {
+		candidates := make([]cacheManifest, 0)
+
+		for _, e := range entries {
+			key, ok := strings.CutSuffix(e.Name(), manifestExt)
+			if !ok || !strings.HasPrefix(key, restoreKey) {
+				continue
+			}
+
+			m, err := readManifest(filepath.Join(storeDir, archiveKeysDir, e.Name()))
+			if err != nil {
+				return cacheManifest{}, false, err
+			}
+
+			candidates = append(candidates, m)
+		}
+
+		if len(candidates) > 0 {
+			newest := slices.MaxFunc(candidates, func(a, b cacheManifest) int {
+				return a.Created.Compare(b.Created)
+			})
+			return newest, false, nil
+		}
+	}
+
+	return cacheManifest{}, false, nil
+}
+
+// verifyBlob makes sure the archive content matches its hash
+func verifyBlob(path, sum string) error {
+	//nolint:gosec // Archive inside the cache store
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Read only file
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return fmt.Errorf("archive %s is corrupted", path)
+	}
+
+	return nil
+}
+
+func extractFile(tr *tar.Reader, target string, header *tar.Header) error {
+	// Cache entries are immutable, keep what is already present
+	if _, err := os.Lstat(target); err == nil {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(target), restoredDirPerm)
+	if err != nil {
+		return err
+	}
+
+	//nolint:gosec // Path validated by the caller
+	f, err := os.OpenFile(
+		target,
+		os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		fs.FileMode(header.Mode).Perm(),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(f, tr, header.Size)
+
+	return errors.Join(err, f.Close())
+}
+
+// extractCacheArchive extracts the archive into `dir`, skipping files that
+// already exist. Directory permissions are applied after all files are written,
+// as module cache directories are read-only.
+func extractCacheArchive(archivePath, dir string) error {
+	//nolint:gosec // Archive inside the cache store
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Read only file
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	dirModes := make(map[string]fs.FileMode)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if _, err := os.Lstat(target); err == nil {
+				continue
+			}
+
+			err := os.MkdirAll(target, restoredDirPerm)
+			if err != nil {
+				return err
+			}
+
+			dirModes[target] = fs.FileMode(header.Mode).Perm()
+		case tar.TypeReg:
+			err := extractFile(tr, target, header)
+			if err != nil {
+				return err
+			}
+		default:
+			// Only directories and regular files are archived
+		}
+	}
+
+	// Deepest directories first, so parents are still writable
+	dirs := slices.Sorted(maps.Keys(dirModes))
+	slices.Reverse(dirs)
+
+	for _, d := range dirs {
+		err := os.Chmod(d, dirModes[d])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreCaches(storeDir string, keys CacheKeys, cache cachePaths) error {
+	manifest, exact, err := findManifest(storeDir, keys)
+	if err != nil {
+		return fmt.Errorf("unable to read cache store at %s: %s", storeDir, err.Error())
+	}
+
+	if manifest.Key == "" {
+		color.Printf(color.WarningColorBold, "No cache found for key: %s\n", keys.Key)
+		return nil
+	}
+
+	for _, c := range cache.named() {
+		sum, ok := manifest.Archives[c.name]
+		if !ok {
+			continue
+		}
+
+		archivePath := filepath.Join(storeDir, archiveBlobsDir, sum+archiveExt)
+
+		err := verifyBlob(archivePath, sum)
+		if err != nil {
+			return fmt.Errorf("unable to restore %s: %s", c.name, err.Error())
+		}
+
+		err = extractCacheArchive(archivePath, c.path)
+		if err != nil {
+			return fmt.Errorf("unable to restore %s to %s: %s", c.name, c.path, err.Error())
+		}
+
+		color.Printf(color.MutedColor, "%s: restored archive %s to %s\n", c.name, sum, c.path)
+	}
+
+	if exact {
+		color.Printf(color.SuccessColorBold, "Restored cache for key: %s\n", manifest.Key)
+	} else {
+		color.Printf(
+			color.SuccessColorBold,
+			"Restored cache from partial match: %s (requested key: %s)\n",
+			manifest.Key,
+			keys.Key,
+		)
+	}
+
+	return nil
+}
+
+func GetCacheArchiveCommand() *cobra.Command {
+	const longDesc = `
+Saves and restores the Go build, module and golangci-lint caches as compressed archives
+in a local directory or a mounted volume. This is meant for CI systems without a cache action.
+
+Archives are content-addressed and shared between keys. Keys are computed the same way
+as 'cache-key'. Restore uses the exact key if present, otherwise the most recent cache
+matching the first possible restore key.
+`
+
+	cacheCommand := &cobra.Command{
+		Use: "cache",
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Save and restore caches used by the tools to a directory",
+		Long:                  longDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	addCacheKeyFlags(cacheCommand.PersistentFlags())
+
+	saveCommand := &cobra.Command{
+		Use: "save <dir>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, err := cacheKeysFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			cache, err := computeCache(false)
+			if err != nil {
+				return err
+			}
+
+			return saveCaches(args[0], keys, cache)
+		},
+		Args:          cobra.ExactArgs(1),
+		Short:         "Save the caches to the directory",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	restoreCommand := &cobra.Command{
+		Use: "restore <dir>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, err := cacheKeysFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			cache, err := computeCache(false)
+			if err != nil {
+				return err
+			}
+
+			return restoreCaches(args[0], keys, cache)
+		},
+		Args:          cobra.ExactArgs(1),
+		Short:         "Restore the caches from the directory",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	cacheCommand.AddCommand(saveCommand, restoreCommand)
+
+	return cacheCommand
+}
@@ -0,0 +1,283 @@
+package listcaches
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/constants"
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/modules"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type CacheKeys struct {
+	Key         string   `json:"key"`
+	RestoreKeys []string `json:"restore-keys"`
+}
+
+const (
+	// Length of hex encoded hashes used in keys
+	keyHashLen = 16
+	keySep     = "-"
+
+	cacheKeyPrefixFlag  = "prefix"
+	cacheKeyModulesFlag = "modules"
+)
+
+func shortHash(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))[:keyHashLen]
+}
+
+// writeHashField writes a length prefixed field, so that
+// different sequences of fields never produce the same input
+func writeHashField(h hash.Hash, name string, content []byte) {
+	//nolint:errcheck,gosec // Writes to hash never fail
+	fmt.Fprintf(h, "%s\n%d\n", name, len(content))
+	//nolint:errcheck,gosec // Writes to hash never fail
+	h.Write(content)
+}
+
+func hashModuleSet(moduleSet []string) string {
+	h := sha256.New()
+
+	for _, m := range moduleSet {
+		writeHashField(h, "module", []byte(m))
+	}
+
+	return shortHash(h)
+}
+
+// hashModuleFiles hashes the go.mod and go.sum content of every module
+func hashModuleFiles(repoRoot string, allModules []string) (string, error) {
+	h := sha256.New()
+
+	for _, m := range allModules {
+		writeHashField(h, "module", []byte(m))
+
+		for _, file := range []string{modules.GoMod, modules.GoSum} {
+			//nolint:gosec // go.mod and go.sum of modules inside the repository
+			content, err := os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(m), file))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+
+			writeHashField(h, file, content)
+		}
+	}
+
+	return shortHash(h), nil
+}
+
+func hashToolVersions() (string, error) {
+	goVersion, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", fmt.Errorf("error while fetching Go version: %s", err.Error())
+	}
+
+	h := sha256.New()
+	writeHashField(h, "go", []byte(strings.TrimSpace(string(goVersion))))
+	writeHashField(h, "golangci-lint", []byte(constants.GolangCILintVersion()))
+
+	return shortHash(h), nil
+}
+
+// normalizeModuleSet returns sorted, de-duplicated, slash separated module paths
+func normalizeModuleSet(moduleSet []string) []string {
+	normalized := make([]string, 0, len(moduleSet))
+
+	for _, m := range moduleSet {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+
+		normalized = append(normalized, filepath.ToSlash(filepath.Clean(m)))
+	}
+
+	slices.Sort(normalized)
+
+	return slices.Compact(normalized)
+}
+
+// computeCacheKeys computes the cache key and the ordered list of restore keys.
+//
+// Key format: <prefix>-<tools>-<checked>-<deps>
+//   - tools: hash of Go version and golangci-lint version
+//   - checked: hash of the set of modules being checked
+//   - deps: hash of go.mod and go.sum of all modules in the repository
+//
+// Restore keys go from the most to least specific and are meant for prefix
+// matching: the same key for all modules, then same modules and tools with
+// any dependencies, then same tools, then just the prefix.
+func computeCacheKeys(
+	repoRoot string,
+	modulesToCheck []string,
+	prefix string,
+) (CacheKeys, error) {
+	allModules, err := modules.FindAllModules(repoRoot)
+	if err != nil {
+		return CacheKeys{}, err
+	}
+
+	allModules = normalizeModuleSet(allModules)
+
+	if len(modulesToCheck) == 0 {
+		modulesToCheck = allModules
+	}
+	modulesToCheck = normalizeModuleSet(modulesToCheck)
+
+	for _, m := range modulesToCheck {
+		if !slices.Contains(allModules, m) {
+			return CacheKeys{}, fmt.Errorf("%s is not a module in the repository", m)
+		}
+	}
+
+	toolsHash, err := hashToolVersions()
+	if err != nil {
+		return CacheKeys{}, err
+	}
+
+	depsHash, err := hashModuleFiles(repoRoot, allModules)
+	if err != nil {
+		return CacheKeys{}, fmt.Errorf("unable to hash module files: %s", err.Error())
+	}
+
+	checkedHash := hashModuleSet(modulesToCheck)
+	allHash := hashModuleSet(allModules)
+
+	toolsPrefix := prefix + keySep + toolsHash + keySep
+
+	restoreKeys := []string{
+		toolsPrefix + allHash + keySep + depsHash,
+		toolsPrefix + checkedHash + keySep,
+		toolsPrefix + allHash + keySep,
+		toolsPrefix,
+		prefix + keySep,
+	}
+
+	key := toolsPrefix + checkedHash + keySep + depsHash
+
+	// Same modules are checked and all modules, remove duplicates
+	restoreKeys = slices.DeleteFunc(restoreKeys, func(k string) bool { return k == key })
+	restoreKeys = slices.Compact(restoreKeys)
+
+	return CacheKeys{Key: key, RestoreKeys: restoreKeys}, nil
+}
+
+func defaultCacheKeyPrefix() string {
+	return "go-ci" + keySep + runtime.GOOS + keySep + runtime.GOARCH
+}
+
+func addCacheKeyFlags(flags *pflag.FlagSet) {
+	flags.String(cacheKeyPrefixFlag, defaultCacheKeyPrefix(), "Prefix for the cache keys")
+	flags.StringSlice(
+		cacheKeyModulesFlag,
+		nil,
+		"Modules being checked, relative to current directory (comma separated). Default is all modules",
+	)
+}
+
+// cacheKeysFromFlags computes the cache keys for the repository in current
+// directory using the flags added by addCacheKeyFlags
+func cacheKeysFromFlags(cmd *cobra.Command) (CacheKeys, error) {
+	prefix, err := cmd.Flags().GetString(cacheKeyPrefixFlag)
+	if err != nil {
+		return CacheKeys{}, err
+	}
+
+	if prefix == "" {
+		return CacheKeys{}, errors.New("prefix for cache key can not be empty")
+	}
+
+	modulesToCheck, err := cmd.Flags().GetStringSlice(cacheKeyModulesFlag)
+	if err != nil {
+		return CacheKeys{}, err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return CacheKeys{}, err
+	}
+
+	return computeCacheKeys(cwd, modulesToCheck, prefix)
+}
+
+func GetCacheKeyCommand() *cobra.Command {
+	const longDesc = `
+Computes a deterministic cache key for the Go build, module and golangci-lint caches,
+along with an ordered list of fallback restore keys.
+
+Key format: <prefix>-<tools>-<checked>-<deps>
+  tools:   hash of Go version and golangci-lint version
+  checked: hash of the sorted set of modules being checked
+  deps:    hash of go.mod and go.sum of every module in the repository
+
+Restore keys are ordered from most to least specific and should be used as prefixes:
+  <prefix>-<tools>-<all>-<deps>  (all modules instead of checked modules)
+  <prefix>-<tools>-<checked>-
+  <prefix>-<tools>-<all>-
+  <prefix>-<tools>-
+  <prefix>-
+`
+
+	const JSONFlag = "json"
+
+	cacheKeyCommand := &cobra.Command{
+		Use: "cache-key",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			isJSON, err := cmd.Flags().GetBool(JSONFlag)
+			if err != nil {
+				return err
+			}
+
+			keys, err := cacheKeysFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			if isJSON {
+				out, err := json.Marshal(keys)
+				if err != nil {
+					return fmt.Errorf("error while formatting cache keys to JSON: %s", err.Error())
+				}
+
+				_, err = os.Stdout.Write(append(out, '\n'))
+				if err != nil {
+					return fmt.Errorf("error while writing JSON output: %s", err.Error())
+				}
+			} else {
+				color.Printf(color.NoColor, "key=%s\n", keys.Key)
+				for _, k := range keys.RestoreKeys {
+					color.Printf(color.NoColor, "restore-key=%s\n", k)
+				}
+			}
+
+			return nil
+		},
+		Args: cobra.NoArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Compute cache keys for the caches used by the tools",
+		Long:                  longDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	addCacheKeyFlags(cacheKeyCommand.Flags())
+	cacheKeyCommand.Flags().Bool(JSONFlag, false, "Print the output in JSON format")
+
+	return cacheKeyCommand
+}
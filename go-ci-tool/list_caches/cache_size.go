@@ -0,0 +1,102 @@
+package listcaches
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const sizeUnit = 1024
+
+// dirSize returns the total size of all the regular files under `dir`.
+// Returns 0 if the directory doesn't exist.
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// File removed while walking
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		size += info.Size()
+
+		return nil
+	})
+
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	return size, err
+}
+
+// formatSize formats the size in bytes into human readable form
+func formatSize(size int64) string {
+	if size < sizeUnit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+	value := float64(size) / sizeUnit
+	i := 0
+
+	for value >= sizeUnit && i < len(units)-1 {
+		value /= sizeUnit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// parseSize parses sizes like "512", "200M", "1.5GB" or "2GiB".
+// All units are treated as powers of 1024.
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "B"), "I")
+
+	multiplier := int64(1)
+
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			multiplier = sizeUnit
+		case 'M':
+			multiplier = sizeUnit * sizeUnit
+		case 'G':
+			multiplier = sizeUnit * sizeUnit * sizeUnit
+		case 'T':
+			multiplier = sizeUnit * sizeUnit * sizeUnit * sizeUnit
+		default:
+			// No unit, size in bytes
+		}
+
+		if multiplier != 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
@@ -1,13 +1,15 @@
-// Package listcaches is used to list caches used by the tools
+// Package listcaches is used to list and manage caches used by the tools
 package listcaches
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 
+	cioutput "github.com/ram-nad/go-monorepo/go-ci-tool/v2/ci_output"
 	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
 	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
 	"github.com/spf13/cobra"
@@ -17,6 +19,20 @@ type cachePaths struct {
 	GoCache           string
 	GoLangCILintCache string
 	GoModCache        string
+	GoPath            string
+	GoEnv             string
+	GoTmpDir          string
+	// Location of golangci-lint binary, empty if not installed
+	GoLangCILintBin string
+}
+
+// Subset of `go env -json` output
+type goEnv struct {
+	GOCACHE    string
+	GOMODCACHE string
+	GOPATH     string
+	GOENV      string
+	GOTMPDIR   string
 }
 
 func computeCache(silent bool) (cachePaths, error) {
@@ -38,113 +54,141 @@ func computeCache(silent bool) (cachePaths, error) {
 		goCILintCache = filepath.Join(userCacheDir, "golangci-lint")
 	}
 
-	goCache, err := exec.Command("go", "env", "GOCACHE").Output()
+	out, err := exec.Command(
+		"go", "env", "-json", "GOCACHE", "GOMODCACHE", "GOPATH", "GOENV", "GOTMPDIR",
+	).Output()
 	if err != nil {
 		if !silent {
 			color.Printf(
 				color.ErrorColor,
-				"Error while fetching GOCACHE value: %s\n",
+				"Error while fetching Go environment values: %s\n",
 				err.Error(),
 			)
 		}
-		return cachePaths{}, errors.New("error in fetching GOCACHE value")
+		return cachePaths{}, errors.New("error in fetching Go environment values")
 	}
 
-	goModeCache, err := exec.Command("go", "env", "GOMODCACHE").Output()
+	env := goEnv{}
+
+	err = json.Unmarshal(out, &env)
 	if err != nil {
 		if !silent {
 			color.Printf(
 				color.ErrorColor,
-				"Error while fetching GOMODCACHE value: %s\n",
+				"Error while parsing Go environment values: %s\n",
 				err.Error(),
 			)
 		}
-		return cachePaths{}, errors.New("error in fetching GOMODCACHE value")
+		return cachePaths{}, errors.New("error in parsing Go environment values")
 	}
 
-	const WhiteSpaces = "\n\r\t\f "
+	// Not being installed is a valid state, reported as empty path
+	goCILintBin, err := exec.LookPath("golangci-lint")
+	if err != nil {
+		goCILintBin = ""
+	}
 
 	cache := cachePaths{
-		GoCache:           strings.Trim(string(goCache), WhiteSpaces),
+		GoCache:           env.GOCACHE,
 		GoLangCILintCache: goCILintCache,
-		GoModCache:        strings.Trim(string(goModeCache), WhiteSpaces),
+		GoModCache:        env.GOMODCACHE,
+		GoPath:            env.GOPATH,
+		GoEnv:             env.GOENV,
+		GoTmpDir:          env.GOTMPDIR,
+		GoLangCILintBin:   goCILintBin,
 	}
 
 	return cache, nil
 }
 
+func (c cachePaths) fields() []cioutput.Field {
+	return []cioutput.Field{
+		{Key: "gocache-dir", EnvName: "GOCACHE", Value: c.GoCache},
+		{Key: "golangci-lint-dir", EnvName: "GOLANGCI_LINT_CACHE", Value: c.GoLangCILintCache},
+		{Key: "gomodcache-dir", EnvName: "GOMODCACHE", Value: c.GoModCache},
+		{Key: "gopath", EnvName: "GOPATH", Value: c.GoPath},
+		{Key: "goenv", EnvName: "GOENV", Value: c.GoEnv},
+		{Key: "gotmpdir", EnvName: "GOTMPDIR", Value: c.GoTmpDir},
+		{Key: "golangci-lint-bin", EnvName: "GOLANGCI_LINT_BIN", Value: c.GoLangCILintBin},
+	}
+}
+
+func printCacheSizes(cache cachePaths) error {
+	caches := []struct {
+		name string
+		path string
+	}{
+		{"GOCACHE", cache.GoCache},
+		{"GOLANGCI_LINT_CACHE", cache.GoLangCILintCache},
+		{"GOMODCACHE", cache.GoModCache},
+	}
+
+	var total int64
+
+	for _, c := range caches {
+		size, err := dirSize(c.path)
+		if err != nil {
+			return fmt.Errorf("unable to compute size of %s: %s", c.name, err.Error())
+		}
+
+		total += size
+
+		color.Printf(color.InfoColor, "%s:%s ", c.name, c.path)
+		color.Printf(color.HighLightColor, "(%s)\n", formatSize(size))
+	}
+
+	color.Printf(color.InfoColorBold, "Total: %s\n", formatSize(total))
+
+	return nil
+}
+
 func GetCacheListCommand() *cobra.Command {
 	longDesc := `
-Lists the different caches used by the tools.
-Currently lists the GOCACHE, GOLANGCI_LINT_CACHE and GOMODCACHE.
+Lists the different caches and locations used by the tools.
+Currently lists GOCACHE, GOLANGCI_LINT_CACHE, GOMODCACHE, GOPATH, GOENV, GOTMPDIR
+and the location of golangci-lint binary (GOLANGCI_LINT_BIN).
 This is used to set environment variables in CI and as output for debugging.
 `
 	const EnvFlag = "env"
 	const OutFlag = "out"
 	const JSONFlag = "json"
+	const ShellFlag = "shell"
+	const SizeFlag = "size"
 
 	cacheListCommand := &cobra.Command{
 		Use: "list-caches",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			envFormat, err := cmd.Flags().GetBool(EnvFlag)
+			format, err := outputFormatFromFlags(cmd, map[string]cioutput.Format{
+				EnvFlag:   cioutput.FormatEnv,
+				OutFlag:   cioutput.FormatGitHubOutput,
+				JSONFlag:  cioutput.FormatJSON,
+				ShellFlag: cioutput.FormatShell,
+			})
 			if err != nil {
 				return err
 			}
 
-			outFormat, err := cmd.Flags().GetBool(OutFlag)
+			showSize, err := cmd.Flags().GetBool(SizeFlag)
 			if err != nil {
 				return err
 			}
 
-			jsonFormat, err := cmd.Flags().GetBool(JSONFlag)
-			if err != nil {
-				return err
-			}
+			// Machine readable output should not be mixed with error messages
+			silent := format != cioutput.FormatText
 
-			cache, err := computeCache(envFormat || outFormat)
+			cache, err := computeCache(silent)
 			if err != nil {
-				if envFormat || outFormat {
+				if silent {
 					return customerrors.NewErrNoLog()
 				}
 				return err
 			}
 
-			switch {
-			case envFormat:
-				color.Printf(color.NoColor, "GOCACHE=%s\n", cache.GoCache)
-				color.Printf(
-					color.NoColor,
-					"GOLANGCI_LINT_CACHE=%s\n",
-					cache.GoLangCILintCache,
-				)
-				color.Printf(color.NoColor, "GOMODCACHE=%s\n", cache.GoModCache)
-			case outFormat:
-				color.Printf(color.NoColor, "gocache-dir=%s\n", cache.GoCache)
-				color.Printf(
-					color.NoColor,
-					"golangci-lint-dir=%s\n",
-					cache.GoLangCILintCache,
-				)
-				color.Printf(color.NoColor, "gomodcache-dir=%s\n", cache.GoModCache)
-			case jsonFormat:
-				color.Printf(
-					color.NoColor,
-					"{\"gocache-dir\": \"%s\", \"golangci-lint-dir\": \"%s\", \"gomodcache-dir\": \"%s\"}\n",
-					cache.GoCache,
-					cache.GoLangCILintCache,
-					cache.GoModCache,
-				)
-			default:
-				color.Printf(color.InfoColor, "GOCACHE:%s\n", cache.GoCache)
-				color.Printf(
-					color.InfoColor,
-					"GOLANGCI_LINT_CACHE:%s\n",
-					cache.GoLangCILintCache,
-				)
-				color.Printf(color.InfoColor, "GOMODCACHE:%s\n", cache.GoModCache)
+			if showSize {
+				return printCacheSizes(cache)
 			}
 
-			return nil
+			return cioutput.Print(format, cache.fields())
 		},
 		Args: cobra.NoArgs,
 		CompletionOptions: cobra.CompletionOptions{
@@ -160,10 +204,34 @@ func GetCacheListCommand() *cobra.Command {
 	cacheListCommand.Flags().
 		BoolP(EnvFlag, "e", false, "Print the output in the form of environment variables")
 	cacheListCommand.Flags().
-		BoolP(JSONFlag, "o", false, "Print the output in the form of key value pairs")
+		BoolP(OutFlag, "o", false, "Print the output in the form of key value pairs (GitHub Actions output)")
+	cacheListCommand.Flags().
+		BoolP(JSONFlag, "j", false, "Print the output in JSON format")
+	cacheListCommand.Flags().
+		Bool(ShellFlag, false, "Print the output as POSIX shell export statements")
 	cacheListCommand.Flags().
-		BoolP(OutFlag, "j", false, "Print the output in JSON format")
-	cacheListCommand.MarkFlagsMutuallyExclusive(EnvFlag, OutFlag, JSONFlag)
+		BoolP(SizeFlag, "s", false, "Print the disk usage of each cache")
+	cacheListCommand.MarkFlagsMutuallyExclusive(EnvFlag, OutFlag, JSONFlag, ShellFlag, SizeFlag)
 
 	return cacheListCommand
 }
+
+// outputFormatFromFlags returns the output format selected by one of the
+// boolean flags, text format if none is set
+func outputFormatFromFlags(
+	cmd *cobra.Command,
+	formatFlags map[string]cioutput.Format,
+) (cioutput.Format, error) {
+	for flag, format := range formatFlags {
+		isSet, err := cmd.Flags().GetBool(flag)
+		if err != nil {
+			return cioutput.FormatText, err
+		}
+
+		if isSet {
+			return format, nil
+		}
+	}
+
+	return cioutput.FormatText, nil
+}
@@ -0,0 +1,574 @@
+package listcaches
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/modules"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+type pruneOptions struct {
+	maxAge  time.Duration
+	maxSize int64
+	dryRun  bool
+}
+
+// Required module versions, separately for full module content and go.mod only
+type requiredModules struct {
+	content map[module.Version]bool
+	goMod   map[module.Version]bool
+}
+
+const (
+	goSumGoModSuffix    = "/go.mod"
+	goToolchainModule   = "golang.org/toolchain"
+	modCacheDownloadDir = "cache/download"
+	modCacheVersionDir  = "@v"
+	writableDirPerm     = fs.FileMode(0o755)
+	// Go and golangci-lint build caches keep entries in directories named
+	// with the first two hex characters of the entry hash
+	cacheSubDirNameLen = 2
+)
+
+// buildCacheEntries lists the entries of a Go build cache style directory
+// (used by both GOCACHE and GOLANGCI_LINT_CACHE), sorted oldest first
+func buildCacheEntries(dir string) ([]cacheEntry, error) {
+	subDirs, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := make([]cacheEntry, 0)
+
+	for _, subDir := range subDirs {
+		if !subDir.IsDir() || len(subDir.Name()) != cacheSubDirNameLen {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(dir, subDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if !file.Type().IsRegular() {
+				continue
+			}
+
+			info, err := file.Info()
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+
+			entries = append(entries, cacheEntry{
+				path:    filepath.Join(dir, subDir.Name(), file.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b cacheEntry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	return entries, nil
+}
+
+// selectEntriesToPrune returns the entries older than max age, plus the oldest
+// entries needed to bring total size under max size.
+// `entries` must be sorted oldest first.
+func selectEntriesToPrune(entries []cacheEntry, opts pruneOptions) []cacheEntry {
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	cutoff := time.Now().Add(-opts.maxAge)
+	toPrune := make([]cacheEntry, 0)
+
+	for _, e := range entries {
+		tooOld := opts.maxAge > 0 && e.modTime.Before(cutoff)
+		tooBig := opts.maxSize > 0 && total > opts.maxSize
+
+		if !tooOld && !tooBig {
+			break
+		}
+
+		toPrune = append(toPrune, e)
+		total -= e.size
+	}
+
+	return toPrune
+}
+
+func pruneBuildCache(name, dir string, opts pruneOptions) error {
+	entries, err := buildCacheEntries(dir)
+	if err != nil {
+		return fmt.Errorf("unable to list %s entries at %s: %s", name, dir, err.Error())
+	}
+
+	toPrune := selectEntriesToPrune(entries, opts)
+
+	var prunedSize int64
+
+	for _, e := range toPrune {
+		if !opts.dryRun {
+			err := os.Remove(e.path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("unable to remove %s: %s", e.path, err.Error())
+			}
+		}
+
+		prunedSize += e.size
+	}
+
+	action := "Removed"
+	if opts.dryRun {
+		action = "Would remove"
+	}
+
+	color.Printf(
+		color.SuccessColor,
+		"%s: %s %d of %d entries (%s)\n",
+		name,
+		action,
+		len(toPrune),
+		len(entries),
+		formatSize(prunedSize),
+	)
+
+	return nil
+}
+
+// addGoSumVersions adds the module versions listed in a go.sum file
+func addGoSumVersions(goSumPath string, required requiredModules) error {
+	//nolint:gosec // go.sum of a module inside the repository
+	f, err := os.Open(goSumPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Read only file
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		//nolint:mnd // go.sum lines have module path, version and hash
+		if len(fields) != 3 {
+			continue
+		}
+
+		if version, ok := strings.CutSuffix(fields[1], goSumGoModSuffix); ok {
+			required.goMod[module.Version{Path: fields[0], Version: version}] = true
+		} else {
+			required.content[module.Version{Path: fields[0], Version: fields[1]}] = true
+		}
+	}
+
+	return scanner.Err()
+}
+
+// addGoModVersions adds the module versions required in a go.mod file,
+// in case go.sum is missing entries
+func addGoModVersions(goModPath string, required requiredModules) error {
+	//nolint:gosec // go.mod of a module inside the repository
+	content, err := os.ReadFile(goModPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := modfile.ParseLax(goModPath, content, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range f.Require {
+		required.content[r.Mod] = true
+		required.goMod[r.Mod] = true
+	}
+
+	return nil
+}
+
+// findRequiredModules collects module versions required by
+// every module in the repository
+func findRequiredModules(repoRoot string) (requiredModules, error) {
+	required := requiredModules{
+		content: make(map[module.Version]bool),
+		goMod:   make(map[module.Version]bool),
+	}
+
+	allModules, err := modules.FindAllModules(repoRoot)
+	if err != nil {
+		return required, err
+	}
+
+	for _, m := range allModules {
+		dir := filepath.Join(repoRoot, m)
+
+		err := addGoModVersions(filepath.Join(dir, modules.GoMod), required)
+		if err != nil {
+			return required, err
+		}
+
+		err = addGoSumVersions(filepath.Join(dir, modules.GoSum), required)
+		if err != nil {
+			return required, err
+		}
+	}
+
+	// Anything required for module content also needs its go.mod
+	for v := range required.content {
+		required.goMod[v] = true
+	}
+
+	return required, nil
+}
+
+// removeTree removes a directory tree, including read-only
+// directories created by the module cache
+func removeTree(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return os.Chmod(path, writableDirPerm)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// unusedDownloads lists files in the module download cache
+// for module versions which are not required
+func unusedDownloads(
+	modCache string,
+	required requiredModules,
+) ([]string, []module.Version, error) {
+	downloadDir := filepath.Join(modCache, filepath.FromSlash(modCacheDownloadDir))
+
+	files := make([]string, 0)
+	versions := make([]module.Version, 0)
+	seen := make(map[module.Version]bool)
+
+	err := filepath.WalkDir(downloadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() || d.Name() != modCacheVersionDir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(downloadDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		modPath, err := module.UnescapePath(filepath.ToSlash(rel))
+		if err != nil || modPath == goToolchainModule {
+			return filepath.SkipDir
+		}
+
+		versionFiles, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range versionFiles {
+			ext := filepath.Ext(f.Name())
+			if f.IsDir() || ext == "" || f.Name() == "list" {
+				continue
+			}
+
+			version, err := module.UnescapeVersion(strings.TrimSuffix(f.Name(), ext))
+			if err != nil {
+				continue
+			}
+
+			v := module.Version{Path: modPath, Version: version}
+
+			keep := required.content[v]
+			if ext == ".mod" || ext == ".info" {
+				keep = keep || required.goMod[v]
+			}
+
+			if keep {
+				continue
+			}
+
+			files = append(files, filepath.Join(path, f.Name()))
+
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+
+		return filepath.SkipDir
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	return files, versions, nil
+}
+
+// unusedExtractedModules lists extracted module directories in the
+// module cache for module versions which are not required
+func unusedExtractedModules(
+	modCache string,
+	required requiredModules,
+) ([]string, error) {
+	downloadDir := filepath.Join(modCache, filepath.FromSlash(modCacheDownloadDir))
+	dirs := make([]string, 0)
+
+	err := filepath.WalkDir(modCache, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() || path == modCache {
+			return nil
+		}
+
+		if path == filepath.Dir(downloadDir) {
+			return filepath.SkipDir
+		}
+
+		escaped, escapedVersion, found := strings.Cut(d.Name(), "@")
+		if !found {
+			return nil
+		}
+
+		rel, err := filepath.Rel(modCache, filepath.Join(filepath.Dir(path), escaped))
+		if err != nil {
+			return err
+		}
+
+		modPath, errPath := module.UnescapePath(filepath.ToSlash(rel))
+		version, errVersion := module.UnescapeVersion(escapedVersion)
+
+		if errPath == nil && errVersion == nil && modPath != goToolchainModule &&
+			!required.content[module.Version{Path: modPath, Version: version}] {
+			dirs = append(dirs, path)
+		}
+
+		return filepath.SkipDir
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+func pruneModCache(modCache string, repoRoot string, dryRun bool) error {
+	required, err := findRequiredModules(repoRoot)
+	if err != nil {
+		return fmt.Errorf("unable to find modules required by the repository: %s", err.Error())
+	}
+
+	files, versions, err := unusedDownloads(modCache, required)
+	if err != nil {
+		return fmt.Errorf("unable to list module download cache: %s", err.Error())
+	}
+
+	dirs, err := unusedExtractedModules(modCache, required)
+	if err != nil {
+		return fmt.Errorf("unable to list extracted modules: %s", err.Error())
+	}
+
+	var prunedSize int64
+
+	for _, v := range versions {
+		color.Printf(color.MutedColor, "%s@%s\n", v.Path, v.Version)
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err == nil {
+			prunedSize += info.Size()
+		}
+
+		if !dryRun {
+			err := os.Remove(file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("unable to remove %s: %s", file, err.Error())
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		size, err := dirSize(dir)
+		if err == nil {
+			prunedSize += size
+		}
+
+		if !dryRun {
+			err := removeTree(dir)
+			if err != nil {
+				return fmt.Errorf("unable to remove %s: %s", dir, err.Error())
+			}
+		}
+	}
+
+	action := "Removed"
+	if dryRun {
+		action = "Would remove"
+	}
+
+	color.Printf(
+		color.SuccessColor,
+		"GOMODCACHE: %s %d unused module versions and %d extracted modules (%s)\n",
+		action,
+		len(versions),
+		len(dirs),
+		formatSize(prunedSize),
+	)
+
+	return nil
+}
+
+func GetPruneCachesCommand() *cobra.Command {
+	const longDesc = `
+Prunes the caches used by the tools.
+
+GOCACHE and GOLANGCI_LINT_CACHE entries are removed when they have not been used
+for longer than '--max-age', and then the least recently used entries are removed
+until each cache fits in '--max-size'.
+
+With '--mod-cache', module versions in GOMODCACHE that are not required by any
+module in current or sub-directories (as per their go.mod and go.sum) are removed.
+`
+
+	const (
+		MaxAgeFlag   = "max-age"
+		MaxSizeFlag  = "max-size"
+		ModCacheFlag = "mod-cache"
+		DryRunFlag   = "dry-run"
+	)
+
+	pruneCachesCommand := &cobra.Command{
+		Use: "prune-caches",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			maxAge, err := cmd.Flags().GetDuration(MaxAgeFlag)
+			if err != nil {
+				return err
+			}
+
+			maxSizeStr, err := cmd.Flags().GetString(MaxSizeFlag)
+			if err != nil {
+				return err
+			}
+
+			pruneModules, err := cmd.Flags().GetBool(ModCacheFlag)
+			if err != nil {
+				return err
+			}
+
+			dryRun, err := cmd.Flags().GetBool(DryRunFlag)
+			if err != nil {
+				return err
+			}
+
+			var maxSize int64
+			if maxSizeStr != "" {
+				maxSize, err = parseSize(maxSizeStr)
+				if err != nil {
+					return err
+				}
+			}
+
+			if maxAge <= 0 && maxSize <= 0 && !pruneModules {
+				return errors.New(
+					"nothing to prune. provide at least one of '--max-age', '--max-size' or '--mod-cache'",
+				)
+			}
+
+			cache, err := computeCache(false)
+			if err != nil {
+				return err
+			}
+
+			if maxAge > 0 || maxSize > 0 {
+				opts := pruneOptions{maxAge: maxAge, maxSize: maxSize, dryRun: dryRun}
+
+				err = pruneBuildCache("GOCACHE", cache.GoCache, opts)
+				if err != nil {
+					return err
+				}
+
+				err = pruneBuildCache("GOLANGCI_LINT_CACHE", cache.GoLangCILintCache, opts)
+				if err != nil {
+					return err
+				}
+			}
+
+			if pruneModules {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+
+				return pruneModCache(cache.GoModCache, cwd, dryRun)
+			}
+
+			return nil
+		},
+		Args: cobra.NoArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Prune the caches used by the tools",
+		Long:                  longDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	pruneCachesCommand.Flags().
+		Duration(MaxAgeFlag, 0, "Remove build and lint cache entries unused for longer than this duration (e.g. 168h)")
+	pruneCachesCommand.Flags().
+		String(MaxSizeFlag, "", "Trim build and lint caches to this size each, removing least recently used entries first (e.g. 2GiB)")
+	pruneCachesCommand.Flags().
+		Bool(ModCacheFlag, false, "Remove module versions not required by any module in the repository from GOMODCACHE")
+	pruneCachesCommand.Flags().
+		Bool(DryRunFlag, false, "Only report what would be removed")
+
+	return pruneCachesCommand
+}
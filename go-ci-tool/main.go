@@ -55,8 +55,16 @@ func main() {
 
 	rootCmd.AddCommand(checktools.GetCheckInstallationCommand())
 	rootCmd.AddCommand(listcaches.GetCacheListCommand())
+	rootCmd.AddCommand(listcaches.GetPruneCachesCommand())
+	rootCmd.AddCommand(listcaches.GetCacheKeyCommand())
+	rootCmd.AddCommand(listcaches.GetCacheArchiveCommand())
 	rootCmd.AddCommand(modules.GetModulesCommand())
 	rootCmd.AddCommand(modules.GetListModulesCommand())
+	rootCmd.AddCommand(modules.GetCheckDependenciesCommand())
+	rootCmd.AddCommand(modules.GetGraphCommand())
+	rootCmd.AddCommand(modules.GetRunToolCommand())
+	rootCmd.AddCommand(modules.GetSBOMCommand())
+	rootCmd.AddCommand(modules.GetReleaseCommand())
 
 	err := rootCmd.Execute()
 	if err != nil {
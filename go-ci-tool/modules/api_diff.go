@@ -0,0 +1,709 @@
+package modules
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// APIChange is a change to the exported API of a package of the module
+type APIChange struct {
+	// Package path relative to the module root, `.` for the root package
+	Package    string
+	Object     string
+	Message    string
+	Compatible bool
+}
+
+// APIDiff between the last release of a module and the working tree
+type APIDiff struct {
+	// Git revision compared with, the latest release tag by default
+	BaseTag string
+	// Release version of the module at BaseTag, e.g. v1.2.3
+	BaseVersion string
+	Changes     []APIChange
+}
+
+type goListExport struct {
+	ImportPath string
+	Name       string
+	Export     string
+	DepOnly    bool
+	Error      *struct {
+		Err string
+	}
+}
+
+type apiComparer struct {
+	oldQualifier types.Qualifier
+	newQualifier types.Qualifier
+	pkg          string
+	changes      []APIChange
+}
+
+// Incompatible returns true if any change breaks users of the module
+func (d APIDiff) Incompatible() bool {
+	return slices.ContainsFunc(d.Changes, func(c APIChange) bool { return !c.Compatible })
+}
+
+// SuggestVersion returns the next version for the changes. Incompatible changes
+// need a new major version unless the module is still at v0.
+func (d APIDiff) SuggestVersion() string {
+	base := semver.Canonical(d.BaseVersion)
+
+	var major, minor, patch int
+	//nolint:errcheck // Canonical version always has three numbers
+	fmt.Sscanf(strings.TrimPrefix(base, "v"), "%d.%d.%d", &major, &minor, &patch)
+
+	switch {
+	case d.Incompatible() && major > 0:
+		return fmt.Sprintf("v%d.0.0", major+1)
+	case d.Incompatible() || len(d.Changes) > 0:
+		return fmt.Sprintf("v%d.%d.0", major, minor+1)
+	default:
+		return fmt.Sprintf("v%d.%d.%d", major, minor, patch+1)
+	}
+}
+
+// loadModuleAPI type-checks the exported API of the non-main, non-internal
+// packages of the module in `dir` from the export data of the compiler,
+// keyed by their path relative to the module root
+func loadModuleAPI(dir string, modulePath string) (map[string]*types.Package, error) {
+	details := ModuleDetails{ModulePath: dir, Module: modulePath}
+
+	out, err := goCommandOutput(
+		details,
+		"list",
+		"-e",
+		"-export",
+		"-deps",
+		"-json=ImportPath,Name,Export,DepOnly,Error",
+		AllModulesPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make(map[string]string)
+	packages := make([]goListExport, 0)
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		pkg := goListExport{}
+
+		err = decoder.Decode(&pkg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'go list' output for module %s: %s", modulePath, err.Error())
+		}
+
+		if pkg.Error != nil {
+			return nil, fmt.Errorf("package %s of module %s has errors: %s", pkg.ImportPath, modulePath, pkg.Error.Err)
+		}
+
+		exports[pkg.ImportPath] = pkg.Export
+
+		if !pkg.DepOnly {
+			packages = append(packages, pkg)
+		}
+	}
+
+	imp := importer.ForCompiler(token.NewFileSet(), "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok || file == "" {
+			return nil, fmt.Errorf("no export data for package %s", path)
+		}
+
+		//nolint:gosec // Export data file in the build cache
+		return os.Open(file)
+	})
+
+	api := make(map[string]*types.Package)
+
+	for _, pkg := range packages {
+		rel := relPackagePath(pkg.ImportPath, modulePath)
+		if pkg.Name == "main" || isInternalPackage(rel) {
+			continue
+		}
+
+		typesPkg, err := imp.Import(pkg.ImportPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load package %s: %s", pkg.ImportPath, err.Error())
+		}
+
+		api[rel] = typesPkg
+	}
+
+	return api, nil
+}
+
+func relPackagePath(pkgPath string, modulePath string) string {
+	if pkgPath == modulePath {
+		return "."
+	}
+
+	return "./" + strings.TrimPrefix(pkgPath, modulePath+"/")
+}
+
+func isInternalPackage(rel string) bool {
+	return slices.Contains(strings.Split(rel, "/"), "internal")
+}
+
+// typeQualifier writes packages of the module relative to its root,
+// so types match between releases with different major versions
+func typeQualifier(modulePath string) types.Qualifier {
+	return func(p *types.Package) string {
+		if p.Path() == modulePath || strings.HasPrefix(p.Path(), modulePath+"/") {
+			return relPackagePath(p.Path(), modulePath)
+		}
+		return p.Path()
+	}
+}
+
+func (c *apiComparer) add(object string, compatible bool, format string, args ...any) {
+	c.changes = append(c.changes, APIChange{
+		Package:    c.pkg,
+		Object:     object,
+		Message:    fmt.Sprintf(format, args...),
+		Compatible: compatible,
+	})
+}
+
+func (c *apiComparer) oldString(t types.Type) string {
+	return types.TypeString(t, c.oldQualifier)
+}
+
+func (c *apiComparer) newString(t types.Type) string {
+	return types.TypeString(t, c.newQualifier)
+}
+
+func objectKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Const:
+		return "constant"
+	case *types.Var:
+		return "variable"
+	case *types.Func:
+		return "function"
+	case *types.TypeName:
+		if o.IsAlias() {
+			return "type alias"
+		}
+		return "type"
+	default:
+		return "object"
+	}
+}
+
+func typeParamsString(params *types.TypeParamList, qualifier types.Qualifier) string {
+	names := make([]string, 0, params.Len())
+	for p := range params.TypeParams() {
+		names = append(names, p.Obj().Name()+" "+types.TypeString(p.Constraint(), qualifier))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// exportedMethods returns the signatures of the exported methods of *T,
+// including promoted ones, keyed by name
+func exportedMethods(t types.Type, qualifier types.Qualifier) map[string]string {
+	methods := make(map[string]string)
+
+	if _, ok := t.Underlying().(*types.Interface); !ok {
+		t = types.NewPointer(t)
+	}
+
+	set := types.NewMethodSet(t)
+	for sel := range set.Methods() {
+		if sel.Obj().Exported() {
+			methods[sel.Obj().Name()] = types.TypeString(sel.Obj().Type(), qualifier)
+		}
+	}
+
+	return methods
+}
+
+func exportedFields(s *types.Struct, qualifier types.Qualifier) map[string]string {
+	fields := make(map[string]string)
+
+	for field := range s.Fields() {
+		if field.Exported() {
+			fields[field.Name()] = types.TypeString(field.Type(), qualifier)
+		}
+	}
+
+	return fields
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// compareMembers reports removed and changed members as incompatible,
+// and added members as compatible unless `addBreaks` is set
+func (c *apiComparer) compareMembers(
+	object string,
+	kind string,
+	oldMembers map[string]string,
+	newMembers map[string]string,
+	addBreaks bool,
+) {
+	for _, name := range sortedKeys(oldMembers) {
+		newType, ok := newMembers[name]
+		if !ok {
+			c.add(object+"."+name, false, "%s removed", kind)
+		} else if newType != oldMembers[name] {
+			c.add(object+"."+name, false, "%s changed from %s to %s", kind, oldMembers[name], newType)
+		}
+	}
+
+	for _, name := range sortedKeys(newMembers) {
+		if _, ok := oldMembers[name]; !ok {
+			c.add(object+"."+name, !addBreaks, "%s added", kind)
+		}
+	}
+}
+
+func (c *apiComparer) compareTypes(name string, oldObj, newObj *types.TypeName) {
+	if oldObj.IsAlias() || newObj.IsAlias() {
+		if c.oldString(oldObj.Type()) != c.newString(newObj.Type()) {
+			c.add(name, false, "changed from %s to %s", c.oldString(oldObj.Type()), c.newString(newObj.Type()))
+		}
+		return
+	}
+
+	oldNamed, oldOk := oldObj.Type().(*types.Named)
+	newNamed, newOk := newObj.Type().(*types.Named)
+
+	if oldOk && newOk {
+		oldParams := typeParamsString(oldNamed.TypeParams(), c.oldQualifier)
+		newParams := typeParamsString(newNamed.TypeParams(), c.newQualifier)
+
+		if oldParams != newParams {
+			c.add(name, false, "type parameters changed from [%s] to [%s]", oldParams, newParams)
+			return
+		}
+	}
+
+	oldUnder := oldObj.Type().Underlying()
+	newUnder := newObj.Type().Underlying()
+
+	oldStruct, oldIsStruct := oldUnder.(*types.Struct)
+	newStruct, newIsStruct := newUnder.(*types.Struct)
+	_, oldIsIface := oldUnder.(*types.Interface)
+	_, newIsIface := newUnder.(*types.Interface)
+
+	switch {
+	case oldIsStruct && newIsStruct:
+		c.compareMembers(
+			name,
+			"field",
+			exportedFields(oldStruct, c.oldQualifier),
+			exportedFields(newStruct, c.newQualifier),
+			false,
+		)
+	case oldIsIface && newIsIface:
+		// Adding a method breaks implementations of the interface
+		c.compareMembers(
+			name,
+			"method",
+			exportedMethods(oldObj.Type(), c.oldQualifier),
+			exportedMethods(newObj.Type(), c.newQualifier),
+			true,
+		)
+		return
+	case c.oldString(oldUnder) != c.newString(newUnder):
+		c.add(name, false, "underlying type changed from %s to %s", c.oldString(oldUnder), c.newString(newUnder))
+		return
+	}
+
+	c.compareMembers(
+		name,
+		"method",
+		exportedMethods(oldObj.Type(), c.oldQualifier),
+		exportedMethods(newObj.Type(), c.newQualifier),
+		false,
+	)
+}
+
+func (c *apiComparer) compareObjects(name string, oldObj, newObj types.Object) {
+	if objectKind(oldObj) != objectKind(newObj) {
+		c.add(name, false, "changed from %s to %s", objectKind(oldObj), objectKind(newObj))
+		return
+	}
+
+	switch o := oldObj.(type) {
+	case *types.TypeName:
+		//nolint:forcetypeassert // Same kind as the old object
+		c.compareTypes(name, o, newObj.(*types.TypeName))
+	case *types.Const:
+		//nolint:forcetypeassert // Same kind as the old object
+		n := newObj.(*types.Const)
+		if c.oldString(o.Type()) != c.newString(n.Type()) {
+			c.add(name, false, "type changed from %s to %s", c.oldString(o.Type()), c.newString(n.Type()))
+		} else if o.Val().ExactString() != n.Val().ExactString() {
+			c.add(name, false, "value changed from %s to %s", o.Val().ExactString(), n.Val().ExactString())
+		}
+	default:
+		if c.oldString(oldObj.Type()) != c.newString(newObj.Type()) {
+			c.add(name, false, "changed from %s to %s", c.oldString(oldObj.Type()), c.newString(newObj.Type()))
+		}
+	}
+}
+
+func (c *apiComparer) comparePackages(oldPkg, newPkg *types.Package) {
+	oldScope := oldPkg.Scope()
+	newScope := newPkg.Scope()
+
+	for _, name := range oldScope.Names() {
+		oldObj := oldScope.Lookup(name)
+		if !oldObj.Exported() {
+			continue
+		}
+
+		newObj := newScope.Lookup(name)
+		if newObj == nil {
+			c.add(name, false, "%s removed", objectKind(oldObj))
+			continue
+		}
+
+		c.compareObjects(name, oldObj, newObj)
+	}
+
+	for _, name := range newScope.Names() {
+		newObj := newScope.Lookup(name)
+		if newObj.Exported() && oldScope.Lookup(name) == nil {
+			c.add(name, true, "%s added", objectKind(newObj))
+		}
+	}
+}
+
+// CompareModuleAPI compares the exported API of the packages of two versions of a module
+func CompareModuleAPI(
+	oldAPI map[string]*types.Package,
+	oldModule string,
+	newAPI map[string]*types.Package,
+	newModule string,
+) []APIChange {
+	c := &apiComparer{
+		oldQualifier: typeQualifier(oldModule),
+		newQualifier: typeQualifier(newModule),
+	}
+
+	oldPackages := make([]string, 0, len(oldAPI))
+	for rel := range oldAPI {
+		oldPackages = append(oldPackages, rel)
+	}
+
+	slices.Sort(oldPackages)
+
+	for _, rel := range oldPackages {
+		c.pkg = rel
+
+		newPkg, ok := newAPI[rel]
+		if !ok {
+			c.add("", false, "package removed")
+			continue
+		}
+
+		c.comparePackages(oldAPI[rel], newPkg)
+	}
+
+	newPackages := make([]string, 0, len(newAPI))
+	for rel := range newAPI {
+		if _, ok := oldAPI[rel]; !ok {
+			newPackages = append(newPackages, rel)
+		}
+	}
+
+	slices.Sort(newPackages)
+
+	for _, rel := range newPackages {
+		c.pkg = rel
+		c.add("", true, "package added")
+	}
+
+	return c.changes
+}
+
+// extractGitTree writes the files of the repository at `root` at `rev` to `dir`
+func extractGitTree(details ModuleDetails, root string, rev string, dir string) error {
+	// Run from the root, in a sub-directory git only archives that directory
+	cmd := exec.Command(GIT, "archive", "--format=tar", rev)
+	cmd.Dir = root
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return fmt.Errorf("error while running 'git archive %s', error: %s", rev, err.Error())
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Print(color.MutedColor, errOut.String())
+		return fmt.Errorf("'git archive %s' failed for module %s", rev, details.Module)
+	}
+
+	tr := tar.NewReader(&out)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("unsafe path %s in 'git archive %s'", header.Name, rev)
+		}
+
+		target := filepath.Join(dir, header.Name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, reportDirPerm)
+		case tar.TypeReg:
+			err = os.MkdirAll(filepath.Dir(target), reportDirPerm)
+			if err == nil {
+				err = writeTarFile(tr, target, header.FileInfo().Mode().Perm())
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeTarFile(r io.Reader, target string, perm os.FileMode) error {
+	//nolint:gosec // Path checked to be inside the extract directory
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	//nolint:gosec // Archive created by git from the repository
+	_, err = io.Copy(f, r)
+
+	return errors.Join(err, f.Close())
+}
+
+// baseReleaseVersion returns the version of a release tag, or of the latest
+// release tag of the module reachable from any other git revision
+func baseReleaseVersion(details ModuleDetails, rev string) (string, error) {
+	version := rev[strings.LastIndex(rev, "/")+1:]
+	if semver.IsValid(version) {
+		return version, nil
+	}
+
+	tag, err := LatestModuleTagAt(details, rev)
+	if err != nil {
+		return "", err
+	}
+
+	if tag == "" {
+		return "", fmt.Errorf(
+			"no release tag of module %s is reachable from %s, unable to find the base version",
+			details.Module,
+			rev,
+		)
+	}
+
+	return tag[strings.LastIndex(tag, "/")+1:], nil
+}
+
+// DiffModuleAPI compares the exported API of the module at `baseTag`,
+// the latest release tag of the module if empty, with the working tree
+func DiffModuleAPI(details ModuleDetails, baseTag string) (APIDiff, error) {
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return APIDiff{}, err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return APIDiff{}, err
+	}
+
+	if baseTag == "" {
+		baseTag, err = LatestModuleTag(details)
+		if err != nil {
+			return APIDiff{}, err
+		}
+	}
+
+	diff := APIDiff{BaseTag: baseTag}
+	if baseTag == "" {
+		return diff, nil
+	}
+
+	diff.BaseVersion, err = baseReleaseVersion(details, baseTag)
+	if err != nil {
+		return diff, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "go-ci-tool-apidiff-*")
+	if err != nil {
+		return diff, err
+	}
+	//nolint:errcheck // Best effort cleanup of temporary directory
+	defer os.RemoveAll(tmpDir)
+
+	color.Printf(color.InfoColor, "Loading API of %s at %s\n", details.Module, baseTag)
+
+	err = extractGitTree(details, root, baseTag, tmpDir)
+	if err != nil {
+		return diff, err
+	}
+
+	oldDetails, err := GetDetailsForModFile(filepath.Join(tmpDir, relDir))
+	if err != nil {
+		return diff, err
+	}
+
+	oldAPI, err := loadModuleAPI(oldDetails.ModulePath, oldDetails.Module)
+	if err != nil {
+		return diff, err
+	}
+
+	color.Printf(color.InfoColor, "Loading API of %s in the working tree\n", details.Module)
+
+	newAPI, err := loadModuleAPI(details.ModulePath, details.Module)
+	if err != nil {
+		return diff, err
+	}
+
+	diff.Changes = CompareModuleAPI(oldAPI, oldDetails.Module, newAPI, details.Module)
+
+	return diff, nil
+}
+
+func printAPIChanges(changes []APIChange) {
+	pkg := ""
+
+	for i, c := range changes {
+		if i == 0 || c.Package != pkg {
+			pkg = c.Package
+			color.Printf(color.InfoColorBold, "\npackage %s\n", pkg)
+		}
+
+		name := c.Object
+		if name != "" {
+			name += ": "
+		}
+
+		if c.Compatible {
+			color.Printf(color.SuccessColor, "  + %s%s\n", name, c.Message)
+		} else {
+			color.Printf(color.ErrorColor, "  - %s%s\n", name, c.Message)
+		}
+	}
+}
+
+// CheckModuleAPI reports API changes since the last release of the module
+// and suggests the next version. Incompatible changes fail unless the
+// module path has already been bumped to a new major version.
+func CheckModuleAPI(details ModuleDetails, baseTag string) error {
+	diff, err := DiffModuleAPI(details, baseTag)
+	if err != nil {
+		return err
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(details.Module)
+
+	if diff.BaseTag == "" {
+		first := "v0.1.0"
+		if pathMajor != "" {
+			first = strings.TrimPrefix(pathMajor, "/") + ".0.0"
+		}
+
+		color.Printf(color.InfoColorBold, "Go module %s has no release tag, suggested first version: %s\n", details.Module, first)
+		return nil
+	}
+
+	printAPIChanges(diff.Changes)
+	color.Println(color.NoColor)
+
+	baseVersion := diff.BaseVersion
+
+	since := diff.BaseTag
+	if !strings.HasSuffix(since, baseVersion) {
+		since = fmt.Sprintf("%s (%s)", diff.BaseTag, baseVersion)
+	}
+
+	// Module path already moved to a new major version since the last release
+	if module.CheckPathMajor(baseVersion, pathMajor) != nil {
+		color.Printf(
+			color.SuccessColorBold,
+			"Go module %s has a new major version since %s, suggested version: %s.0.0\n",
+			details.Module,
+			since,
+			strings.TrimPrefix(pathMajor, "/"),
+		)
+		return nil
+	}
+
+	incompatible := 0
+	for _, c := range diff.Changes {
+		if !c.Compatible {
+			incompatible++
+		}
+	}
+
+	suggested := diff.SuggestVersion()
+
+	if incompatible > 0 && semver.Major(suggested) != semver.Major(baseVersion) {
+		color.Printf(
+			color.ErrorColorBold,
+			"Go module %s has %d incompatible API changes since %s, they need major version %s with module path suffix /%s\n",
+			details.Module,
+			incompatible,
+			since,
+			suggested,
+			semver.Major(suggested),
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Go module %s has %d API changes (%d incompatible) since %s, suggested version: %s\n",
+		details.Module,
+		len(diff.Changes),
+		incompatible,
+		diff.BaseTag,
+		suggested,
+	)
+
+	return nil
+}
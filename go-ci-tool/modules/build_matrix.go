@@ -0,0 +1,245 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+)
+
+// BuildTarget is a GOOS/GOARCH pair, empty values build for the host
+type BuildTarget struct {
+	GOOS   string
+	GOARCH string
+}
+
+// BuildOptions for building a module for multiple targets
+type BuildOptions struct {
+	Targets []BuildTarget
+	// "0", "1" or empty to keep the environment value
+	CGOEnabled string
+	Tags       []string
+	// Maximum number of targets built at the same time
+	Parallel int
+}
+
+type buildResult struct {
+	target   BuildTarget
+	output   string
+	passed   bool
+	duration time.Duration
+	err      error
+}
+
+const hostTarget = "host"
+
+func (t BuildTarget) String() string {
+	if t.GOOS == "" && t.GOARCH == "" {
+		return hostTarget
+	}
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// ParseBuildTargets parses `GOOS/GOARCH` values and validates them
+// against the platforms supported by the Go toolchain
+func ParseBuildTargets(values []string) ([]BuildTarget, error) {
+	supported, err := supportedPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]BuildTarget, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if value == hostTarget {
+			targets = append(targets, BuildTarget{})
+			continue
+		}
+
+		if !slices.Contains(supported, value) {
+			return nil, fmt.Errorf(
+				"unsupported build target %q, run 'go tool dist list' for supported GOOS/GOARCH pairs",
+				value,
+			)
+		}
+
+		goos, goarch, _ := strings.Cut(value, "/")
+
+		target := BuildTarget{GOOS: goos, GOARCH: goarch}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+func buildForTarget(
+	details ModuleDetails,
+	target BuildTarget,
+	opts BuildOptions,
+) buildResult {
+	result := buildResult{target: target}
+
+	// Builds all the packages and discards the results. Unlike an output
+	// directory this doesn't skip non-main packages, and parallel builds
+	// don't write binaries into the module.
+	args := []string{"build", "-trimpath", "-buildvcs=false", "-o", os.DevNull}
+	if len(opts.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(opts.Tags, ","))
+	}
+
+	args = append(args, AllModulesPath)
+
+	cmd := exec.Command(GO, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	if target.GOOS != "" {
+		cmd.Env = append(cmd.Env, "GOOS="+target.GOOS, "GOARCH="+target.GOARCH)
+	}
+
+	if opts.CGOEnabled != "" {
+		cmd.Env = append(cmd.Env, "CGO_ENABLED="+opts.CGOEnabled)
+	}
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	start := time.Now()
+	err := cmd.Run()
+	result.duration = time.Since(start)
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		result.err = fmt.Errorf(
+			"error while running 'go build' for module %s and target %s, error: %s",
+			details.Module,
+			target,
+			err.Error(),
+		)
+		return result
+	}
+
+	result.output = out.String()
+	result.passed = cmd.ProcessState.ExitCode() == 0
+
+	return result
+}
+
+func printBuildMatrix(results []buildResult, opts BuildOptions) {
+	cgo := opts.CGOEnabled
+	if cgo == "" {
+		cgo = "default"
+	}
+
+	tags := strings.Join(opts.Tags, ",")
+	if tags == "" {
+		tags = "-"
+	}
+
+	rows := make([]color.TableRow, 0, len(results))
+
+	for _, r := range results {
+		status, rowColor := "pass", color.SuccessColor
+		if !r.passed {
+			status, rowColor = "fail", color.ErrorColorBold
+		}
+
+		rows = append(rows, color.TableRow{
+			Color: rowColor,
+			Cells: []string{r.target.String(), cgo, tags, status, r.duration.Round(time.Millisecond).String()},
+		})
+	}
+
+	color.PrintTable([]string{"TARGET", "CGO_ENABLED", "TAGS", "RESULT", "DURATION"}, rows)
+}
+
+// RunModuleBuildMatrix cross-compiles all the packages of the module
+// for every target in parallel and reports a pass/fail matrix
+func RunModuleBuildMatrix(details ModuleDetails, opts BuildOptions) error {
+	if len(opts.Targets) == 0 {
+		opts.Targets = []BuildTarget{{}}
+	}
+
+	parallel := max(opts.Parallel, 1)
+
+	color.Printf(
+		color.InfoColor,
+		"go build ./... for %d targets (parallel %d)\n",
+		len(opts.Targets),
+		parallel,
+	)
+
+	results := make([]buildResult, len(opts.Targets))
+	semaphore := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+
+	for i, target := range opts.Targets {
+		wg.Go(func() {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i] = buildForTarget(details, target, opts)
+		})
+	}
+
+	wg.Wait()
+
+	for _, r := range results {
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	for _, r := range results {
+		if r.output == "" {
+			continue
+		}
+
+		color.Printf(color.InfoColorBold, "\n%s\n", r.target)
+		color.Print(color.MutedColor, r.output)
+	}
+
+	color.Println(color.NoColor)
+	printBuildMatrix(results, opts)
+	color.Println(color.NoColor)
+
+	failed := 0
+
+	for _, r := range results {
+		if !r.passed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"Go module %s failed to build for %d of %d targets\n",
+			details.Module,
+			failed,
+			len(results),
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Go module %s builds for all %d targets :)\n",
+		details.Module,
+		len(results),
+	)
+
+	return nil
+}
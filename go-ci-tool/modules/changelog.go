@@ -0,0 +1,285 @@
+package modules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+)
+
+// Commit parsed as a conventional commit, `Type` is empty for other commits
+type Commit struct {
+	Hash        string
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+}
+
+type changelogGroup struct {
+	title string
+	types []string
+}
+
+const (
+	ChangelogFile    = "CHANGELOG.md"
+	changelogHeading = "# Changelog"
+	unreleased       = "Unreleased"
+	shortHashLength  = 7
+)
+
+// Sections of the changelog in order, commits of other types go to the last one
+//
+//nolint:gochecknoglobals // Constant table of changelog sections
+var changelogGroups = []changelogGroup{
+	{title: "Features", types: []string{"feat"}},
+	{title: "Bug Fixes", types: []string{"fix"}},
+	{title: "Performance Improvements", types: []string{"perf"}},
+	{title: "Reverts", types: []string{"revert"}},
+	{title: "Code Refactoring", types: []string{"refactor", "style"}},
+	{title: "Documentation", types: []string{"docs"}},
+	{title: "Tests", types: []string{"test"}},
+	{title: "Build System and CI", types: []string{"build", "ci"}},
+	{title: "Chores", types: []string{"chore"}},
+}
+
+//nolint:gochecknoglobals // Compiled once, used to parse every commit subject
+var conventionalCommitRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// ParseCommit parses the subject and body of a commit message
+func ParseCommit(hash, subject, body string) Commit {
+	commit := Commit{Hash: hash, Description: strings.TrimSpace(subject)}
+
+	match := conventionalCommitRegex.FindStringSubmatch(commit.Description)
+	if match != nil {
+		commit.Type = strings.ToLower(match[1])
+		commit.Scope = match[2]
+		commit.Breaking = match[3] != ""
+		commit.Description = match[4]
+	}
+
+	for line := range strings.Lines(body) {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			commit.Breaking = true
+		}
+	}
+
+	return commit
+}
+
+// ModuleCommits returns the commits since `since` (all commits if empty)
+// touching the directory of the module but not its nested modules, latest first
+func ModuleCommits(details ModuleDetails, since string) ([]Commit, error) {
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return nil, err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return nil, err
+	}
+
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields and commits are separated by unit and record separators
+	args := []string{"log", "--no-merges", "--format=%H%x1f%s%x1f%b%x1e"}
+	if since != "" {
+		args = append(args, since+"..HEAD")
+	}
+
+	pathspec := ":(top)"
+	if relDir != "." {
+		pathspec += filepath.ToSlash(relDir)
+	}
+
+	args = append(args, "--", pathspec)
+
+	for _, m := range modules {
+		if m != relDir && (relDir == "." || strings.HasPrefix(m, relDir+string(filepath.Separator))) {
+			args = append(args, ":(top,exclude)"+filepath.ToSlash(m))
+		}
+	}
+
+	out, err := gitOutput(details.ModulePath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]Commit, 0)
+
+	for record := range strings.SplitSeq(string(out), "\x1e") {
+		hash, rest, ok := strings.Cut(strings.TrimSpace(record), "\x1f")
+		if !ok {
+			continue
+		}
+
+		subject, body, _ := strings.Cut(rest, "\x1f")
+
+		commits = append(commits, ParseCommit(hash, subject, body))
+	}
+
+	return commits, nil
+}
+
+func changelogEntry(c Commit) string {
+	entry := "- "
+	if c.Scope != "" {
+		entry += "**" + c.Scope + ":** "
+	}
+
+	return entry + c.Description + " (" + c.Hash[:min(len(c.Hash), shortHashLength)] + ")\n"
+}
+
+// RenderChangelog renders a Markdown changelog section with the commits
+// grouped by conventional commit type, breaking changes first
+func RenderChangelog(version string, date time.Time, commits []Commit) string {
+	buf := bytes.Buffer{}
+
+	fmt.Fprintf(&buf, "## %s (%s)\n", version, date.Format(time.DateOnly))
+
+	section := func(title string, entries []Commit) {
+		if len(entries) == 0 {
+			return
+		}
+
+		fmt.Fprintf(&buf, "\n### %s\n\n", title)
+
+		for _, c := range entries {
+			buf.WriteString(changelogEntry(c))
+		}
+	}
+
+	breaking := make([]Commit, 0)
+	grouped := make(map[string][]Commit)
+	other := make([]Commit, 0)
+
+	for _, c := range commits {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+
+		found := false
+
+		for _, g := range changelogGroups {
+			for _, t := range g.types {
+				if c.Type == t {
+					grouped[g.title] = append(grouped[g.title], c)
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			other = append(other, c)
+		}
+	}
+
+	section("Breaking Changes", breaking)
+
+	for _, g := range changelogGroups {
+		section(g.title, grouped[g.title])
+	}
+
+	section("Other Changes", other)
+
+	if len(commits) == 0 {
+		buf.WriteString("\nNo changes.\n")
+	}
+
+	return buf.String()
+}
+
+// PrependChangelog adds the section at the top of the changelog file,
+// below its `# Changelog` heading, creating the file if needed
+func PrependChangelog(file string, section string) error {
+	//nolint:gosec // Changelog of the module
+	content, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	existing := string(content)
+	heading := changelogHeading + "\n\n"
+
+	if rest, ok := strings.CutPrefix(existing, changelogHeading+"\n"); ok {
+		existing = strings.TrimLeft(rest, "\n")
+	}
+
+	updated := heading + section
+	if existing != "" {
+		updated += "\n" + existing
+	}
+
+	return os.WriteFile(file, []byte(updated), reportFilePerm)
+}
+
+// GenerateChangelog renders the changes of the module since `since`, the latest
+// release tag of the module if empty, and optionally prepends it to CHANGELOG.md
+func GenerateChangelog(
+	details ModuleDetails,
+	since string,
+	version string,
+	write bool,
+) error {
+	var err error
+
+	if since == "" {
+		since, err = LatestModuleTag(details)
+		if err != nil {
+			return err
+		}
+	}
+
+	commits, err := ModuleCommits(details, since)
+	if err != nil {
+		return err
+	}
+
+	date, err := sourceDate()
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		version = unreleased
+	}
+
+	section := RenderChangelog(version, date, commits)
+
+	if !write {
+		color.Print(color.NoColor, section)
+		return nil
+	}
+
+	file := filepath.Join(details.ModulePath, ChangelogFile)
+
+	err = PrependChangelog(file, section)
+	if err != nil {
+		return fmt.Errorf("unable to update %s: %s", file, err.Error())
+	}
+
+	from := since
+	if from == "" {
+		from = "the first commit"
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Added %d changes since %s to %s :)\n",
+		len(commits),
+		from,
+		file,
+	)
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	textdiff "github.com/ram-nad/go-monorepo/go-ci-tool/v2/text_diff"
+)
+
+// Subset of golangci-lint JSON output we care about
+type lintIssue struct {
+	FromLinter     string          `json:"FromLinter"`
+	SuggestedFixes json.RawMessage `json:"SuggestedFixes"`
+	Replacement    json.RawMessage `json:"Replacement"`
+}
+
+type lintReport struct {
+	Issues []lintIssue `json:"Issues"`
+}
+
+const (
+	// golangci-lint exits with this code when issues are found
+	lintIssuesExitCode = 1
+	jsonNull           = "null"
+)
+
+func (i lintIssue) isFixable() bool {
+	hasValue := func(v json.RawMessage) bool {
+		trimmed := strings.TrimSpace(string(v))
+		return trimmed != "" && trimmed != jsonNull && trimmed != "[]"
+	}
+
+	return hasValue(i.SuggestedFixes) || hasValue(i.Replacement)
+}
+
+// golangCILintConfigArgs returns the arguments to make golangci-lint use the
+// same configuration file as the module, when run from a different directory
+func golangCILintConfigArgs(details ModuleDetails) []string {
+	cmd := exec.Command(GolangCILint, "config", "path")
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	configPath := strings.TrimSpace(string(out))
+	if configPath == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(details.ModulePath, configPath)
+	}
+
+	return []string{"--config", configPath}
+}
+
+// findFixableLinters returns the sorted list of linters reporting issues
+// with auto-fixes for the module
+func findFixableLinters(details ModuleDetails, linters []string) ([]string, error) {
+	reportFile, err := os.CreateTemp("", "go-ci-tool-lint-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create lint report file: %s", err.Error())
+	}
+
+	reportPath := reportFile.Name()
+	//nolint:errcheck,gosec // Empty file created only to reserve the name
+	reportFile.Close()
+	//nolint:errcheck // Best effort cleanup of temporary file
+	defer os.Remove(reportPath)
+
+	args := []string{"run", "--output.json.path", reportPath}
+	if len(linters) > 0 {
+		args = append(args, "--enable-only", strings.Join(linters, ","))
+	}
+	args = append(args, AllModulesPath)
+
+	cmd := exec.Command(GolangCILint, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'golangci-lint run ./...' for module %s, error: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if exitCode != 0 && exitCode != lintIssuesExitCode {
+		color.Print(color.MutedColor, out.String())
+		return nil, fmt.Errorf("'golangci-lint run ./...' failed for module %s", details.Module)
+	}
+
+	//nolint:gosec // Temporary file created above
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read lint report: %s", err.Error())
+	}
+
+	report := lintReport{}
+
+	err = json.Unmarshal(content, &report)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse lint report: %s", err.Error())
+	}
+
+	fixable := make([]string, 0)
+
+	for _, issue := range report.Issues {
+		if issue.isFixable() && !slices.Contains(fixable, issue.FromLinter) {
+			fixable = append(fixable, issue.FromLinter)
+		}
+	}
+
+	slices.Sort(fixable)
+
+	return fixable, nil
+}
+
+// fixDiffsForLinter applies the fixes of a single linter on a scratch copy
+// of the module, and returns the diffs keyed by file
+func fixDiffsForLinter(
+	details ModuleDetails,
+	linter string,
+	configArgs []string,
+	originalFiles map[string]string,
+) (map[string]string, error) {
+	scratch, err := CopyModuleToScratch(details)
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Remove()
+
+	args := []string{"run", "--fix", "--enable-only", linter}
+	args = append(args, configArgs...)
+	args = append(args, AllModulesPath)
+
+	cmd := exec.Command(GolangCILint, args...)
+	cmd.Dir = scratch.Dir
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'golangci-lint run --fix' for linter %s, error: %s",
+			linter,
+			err.Error(),
+		)
+	}
+
+	exitCode := cmd.ProcessState.ExitCode()
+	if exitCode != 0 && exitCode != lintIssuesExitCode {
+		color.Print(color.MutedColor, out.String())
+		return nil, fmt.Errorf("'golangci-lint run --fix' failed for linter %s", linter)
+	}
+
+	fixedFiles, err := scratch.Files()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fixed files: %s", err.Error())
+	}
+
+	return textdiff.FileDiffs(originalFiles, fixedFiles), nil
+}
+
+// PreviewGolangCILintFix shows the auto-fixes golangci-lint would apply to the
+// module as unified diffs grouped by linter, without modifying the module.
+// Fixes of each linter are applied separately on a scratch copy of the module.
+func PreviewGolangCILintFix(details ModuleDetails, linters []string) error {
+	color.Println(color.InfoColor, "golangci-lint run --fix ./... (dry run)")
+
+	fixable, err := findFixableLinters(details, linters)
+	if err != nil {
+		return err
+	}
+
+	if len(fixable) == 0 {
+		color.Printf(color.SuccessColorBold, "No auto-fixable lint issues for module: %s\n", details.Module)
+		return nil
+	}
+
+	originalFiles, err := ListModuleFiles(details.ModulePath)
+	if err != nil {
+		return fmt.Errorf("unable to read files for module %s: %s", details.Module, err.Error())
+	}
+
+	configArgs := golangCILintConfigArgs(details)
+	withChanges := make([]string, 0, len(fixable))
+
+	for _, linter := range fixable {
+		diffs, err := fixDiffsForLinter(details, linter, configArgs, originalFiles)
+		if err != nil {
+			return err
+		}
+
+		if len(diffs) == 0 {
+			continue
+		}
+
+		withChanges = append(withChanges, linter)
+
+		color.Printf(color.InfoColorBold, "\nLinter: %s\n", linter)
+
+		for _, file := range slices.Sorted(maps.Keys(diffs)) {
+			textdiff.PrintColored(diffs[file])
+		}
+	}
+
+	color.Println(color.NoColor)
+
+	if len(withChanges) == 0 {
+		color.Printf(color.WarningColorBold, "Auto-fixes for module: %s produced no changes\n", details.Module)
+		return nil
+	}
+
+	color.Printf(
+		color.WarningColorBold,
+		"Auto-fixes are available for module: %s from linters: %s\n",
+		details.Module,
+		strings.Join(withChanges, ", "),
+	)
+	color.Printf(
+		color.InfoColor,
+		"Run with '--fix --linters <linter,...>' to apply fixes of selected linters only.\n",
+	)
+
+	return nil
+}
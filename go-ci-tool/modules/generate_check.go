@@ -0,0 +1,92 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	textdiff "github.com/ram-nad/go-monorepo/go-ci-tool/v2/text_diff"
+)
+
+// CheckModuleGenerated runs `go generate ./...` in a scratch copy of the
+// module and reports the generated files that differ from the working tree
+func CheckModuleGenerated(details ModuleDetails) error {
+	color.Println(color.InfoColor, "go generate ./... (in scratch copy)")
+
+	originalFiles, err := ListModuleFiles(details.ModulePath)
+	if err != nil {
+		return fmt.Errorf("unable to read files for module %s: %s", details.Module, err.Error())
+	}
+
+	scratch, err := CopyModuleToScratch(details)
+	if err != nil {
+		return err
+	}
+	defer scratch.Remove()
+
+	cmd := exec.Command(GO, "generate", AllModulesPath)
+	cmd.Dir = scratch.Dir
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return fmt.Errorf(
+			"error while running 'go generate' for module %s, error: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if out.Len() > 0 {
+		color.Println(color.NoColor)
+		color.Print(color.MutedColor, out.String())
+		color.Println(color.NoColor)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		return fmt.Errorf("'go generate' failed for module %s", details.Module)
+	}
+
+	generatedFiles, err := scratch.Files()
+	if err != nil {
+		return fmt.Errorf("unable to read generated files: %s", err.Error())
+	}
+
+	diffs := textdiff.FileDiffs(originalFiles, generatedFiles)
+
+	if len(diffs) == 0 {
+		color.Printf(color.SuccessColorBold, "Generated files of go module %s are up to date :)\n", details.Module)
+		return nil
+	}
+
+	changed := slices.Sorted(maps.Keys(diffs))
+
+	for _, file := range changed {
+		textdiff.PrintColored(diffs[file])
+	}
+
+	color.Println(color.NoColor)
+	color.Printf(
+		color.ErrorColorBold,
+		"Go module %s has %d stale generated files. Run 'go generate ./...'\n",
+		details.Module,
+		len(changed),
+	)
+
+	for _, file := range changed {
+		color.Printf(color.ErrorColor, "  %s\n", file)
+	}
+
+	return customerrors.NewErrNoLog()
+}
@@ -0,0 +1,368 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type graphNode struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	External bool   `json:"external"`
+}
+
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DependencyGraph is a graph of modules or packages ready to be written out
+type DependencyGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+type goListImports struct {
+	ImportPath string
+	Imports    []string
+	Standard   bool
+	Module     *struct {
+		Path string
+	}
+}
+
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+	// Node external dependencies are collapsed into
+	externalNode = "external"
+)
+
+func (g *DependencyGraph) addNode(id, label string, external bool) {
+	if !slices.ContainsFunc(g.Nodes, func(n graphNode) bool { return n.ID == id }) {
+		g.Nodes = append(g.Nodes, graphNode{ID: id, Label: label, External: external})
+	}
+}
+
+func (g *DependencyGraph) addEdge(from, to string) {
+	edge := graphEdge{From: from, To: to}
+	if from != to && !slices.Contains(g.Edges, edge) {
+		g.Edges = append(g.Edges, edge)
+	}
+}
+
+func (g *DependencyGraph) sort() {
+	slices.SortFunc(g.Nodes, func(a, b graphNode) int { return strings.Compare(a.ID, b.ID) })
+	slices.SortFunc(g.Edges, func(a, b graphEdge) int {
+		return strings.Compare(a.From+"\x00"+a.To, b.From+"\x00"+b.To)
+	})
+}
+
+// ModuleDependencyGraph returns the go.mod requirements between the modules under
+// `root`. With `external` the direct requirements on other modules are included,
+// collapsed into a single node with `collapse`.
+func ModuleDependencyGraph(
+	root string,
+	external bool,
+	collapse bool,
+) (DependencyGraph, error) {
+	graph := DependencyGraph{}
+
+	repo, err := BuildRepoGraph(root)
+	if err != nil {
+		return graph, err
+	}
+
+	for _, m := range repo.Modules {
+		graph.addNode(m.Path, m.Path, false)
+
+		for _, to := range repo.Requires[m.Path] {
+			graph.addEdge(m.Path, to)
+		}
+
+		if !external {
+			continue
+		}
+
+		details, err := GetDetailsForModFile(filepath.Join(root, m.Dir))
+		if err != nil {
+			return graph, err
+		}
+
+		for _, r := range details.Requires {
+			if r.Indirect || slices.Contains(repo.Requires[m.Path], r.Path) {
+				continue
+			}
+
+			if collapse {
+				graph.addNode(externalNode, externalNode, true)
+				graph.addEdge(m.Path, externalNode)
+			} else {
+				graph.addNode(r.Path, r.Path, true)
+				graph.addEdge(m.Path, r.Path)
+			}
+		}
+	}
+
+	graph.sort()
+
+	return graph, nil
+}
+
+// PackageDependencyGraph returns the package imports of the module. With `external`
+// imports of packages from other modules are included, collapsed into their
+// module with `collapse`. Standard library packages are never included.
+func PackageDependencyGraph(
+	details ModuleDetails,
+	external bool,
+	collapse bool,
+) (DependencyGraph, error) {
+	graph := DependencyGraph{}
+
+	out, err := goCommandOutput(
+		details,
+		"list",
+		"-deps",
+		"-json=ImportPath,Imports,Standard,Module",
+		AllModulesPath,
+	)
+	if err != nil {
+		return graph, err
+	}
+
+	packages := make(map[string]goListImports)
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		pkg := goListImports{}
+
+		err = decoder.Decode(&pkg)
+		if err != nil {
+			return graph, fmt.Errorf("unable to parse 'go list' output for module %s: %s", details.Module, err.Error())
+		}
+
+		packages[pkg.ImportPath] = pkg
+	}
+
+	inModule := func(pkg goListImports) bool {
+		return pkg.Module != nil && pkg.Module.Path == details.Module
+	}
+
+	for _, pkg := range packages {
+		if !inModule(pkg) {
+			continue
+		}
+
+		graph.addNode(pkg.ImportPath, relPackagePath(pkg.ImportPath, details.Module), false)
+
+		for _, imp := range pkg.Imports {
+			dep, ok := packages[imp]
+			if !ok || dep.Standard {
+				continue
+			}
+
+			switch {
+			case inModule(dep):
+				graph.addEdge(pkg.ImportPath, dep.ImportPath)
+			case !external:
+				continue
+			case collapse && dep.Module != nil:
+				graph.addNode(dep.Module.Path, dep.Module.Path, true)
+				graph.addEdge(pkg.ImportPath, dep.Module.Path)
+			default:
+				graph.addNode(dep.ImportPath, dep.ImportPath, true)
+				graph.addEdge(pkg.ImportPath, dep.ImportPath)
+			}
+		}
+	}
+
+	graph.sort()
+
+	return graph, nil
+}
+
+// WriteDOT writes the graph in Graphviz DOT format, external nodes are dashed
+func (g DependencyGraph) WriteDOT(w io.Writer) error {
+	buf := bytes.Buffer{}
+
+	buf.WriteString("digraph dependencies {\n\trankdir=LR;\n\tnode [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		style := ""
+		if n.External {
+			style = ", style=dashed"
+		}
+
+		fmt.Fprintf(&buf, "\t%s [label=%s%s];\n", strconv.Quote(n.ID), strconv.Quote(n.Label), style)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "\t%s -> %s;\n", strconv.Quote(e.From), strconv.Quote(e.To))
+	}
+
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, external nodes are rounded
+func (g DependencyGraph) WriteMermaid(w io.Writer) error {
+	buf := bytes.Buffer{}
+	ids := make(map[string]string)
+
+	buf.WriteString("graph LR\n")
+
+	for i, n := range g.Nodes {
+		// Mermaid ids can't contain most characters of import paths
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+
+		label := strings.ReplaceAll(n.Label, `"`, "#quot;")
+		if n.External {
+			fmt.Fprintf(&buf, "    %s([\"%s\"])\n", ids[n.ID], label)
+		} else {
+			fmt.Fprintf(&buf, "    %s[\"%s\"]\n", ids[n.ID], label)
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "    %s --> %s\n", ids[e.From], ids[e.To])
+	}
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// WriteJSON writes the nodes and edges of the graph as JSON
+func (g DependencyGraph) WriteJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(out, '\n'))
+
+	return err
+}
+
+func GetGraphCommand() *cobra.Command {
+	const graphLongHelpDesc = `
+Print the dependency graph of the Go modules in current or sub-directories, from their go.mod requirements
+on each other. With --packages, print the package import graph of a single module instead.
+Formats are Graphviz DOT, Mermaid and JSON. External dependencies are left out unless --external is set,
+--collapse groups them into a single node for modules or into their module for packages.
+`
+
+	const (
+		FormatFlag   = "format"
+		PackagesFlag = "packages"
+		ExternalFlag = "external"
+		CollapseFlag = "collapse"
+	)
+
+	graphCommand := &cobra.Command{
+		Use: "graph",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := cmd.Flags().GetString(FormatFlag)
+			if err != nil {
+				return err
+			}
+
+			if !slices.Contains([]string{FormatDOT, FormatMermaid, FormatJSON}, format) {
+				return fmt.Errorf("unknown graph format %q, use one of: dot, mermaid, json", format)
+			}
+
+			packages, err := cmd.Flags().GetBool(PackagesFlag)
+			if err != nil {
+				return err
+			}
+
+			external, err := cmd.Flags().GetBool(ExternalFlag)
+			if err != nil {
+				return err
+			}
+
+			collapse, err := cmd.Flags().GetBool(CollapseFlag)
+			if err != nil {
+				return err
+			}
+
+			var graph DependencyGraph
+
+			if packages {
+				_, absModulePath, err := resolveModulePath(cmd)
+				if err != nil {
+					return err
+				}
+
+				moduleDetails, err := GetDetailsForModFile(absModulePath)
+				if err != nil {
+					return err
+				}
+
+				graph, err = PackageDependencyGraph(moduleDetails, external, collapse)
+				if err != nil {
+					return err
+				}
+			} else {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+
+				graph, err = ModuleDependencyGraph(cwd, external, collapse)
+				if err != nil {
+					return err
+				}
+			}
+
+			switch format {
+			case FormatMermaid:
+				return graph.WriteMermaid(os.Stdout)
+			case FormatJSON:
+				return graph.WriteJSON(os.Stdout)
+			default:
+				return graph.WriteDOT(os.Stdout)
+			}
+		},
+		Args: cobra.NoArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Print the module or package dependency graph as DOT, Mermaid or JSON",
+		Long:                  graphLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	graphCommand.Flags().
+		String(FormatFlag, FormatDOT, "Output format: dot, mermaid or json")
+	graphCommand.Flags().
+		Bool(PackagesFlag, false, "Print the package import graph of the module instead of the module graph")
+	graphCommand.Flags().
+		Bool(ExternalFlag, false, "Include dependencies outside of the repository (or module for --packages)")
+	graphCommand.Flags().
+		Bool(CollapseFlag, false, "Collapse external dependencies into a single node, or their module for --packages")
+	graphCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory for --packages. Default is root of current module")
+
+	err := graphCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return graphCommand
+}
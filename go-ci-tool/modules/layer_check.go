@@ -0,0 +1,247 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	"github.com/spf13/cobra"
+)
+
+// LayerRule denies modules in directories matching `From` to import
+// packages of modules in directories matching any of `Deny`
+type LayerRule struct {
+	From   string   `json:"from"`
+	Deny   []string `json:"deny"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+type LayerRules struct {
+	Rules []LayerRule `json:"rules"`
+}
+
+type layerViolation struct {
+	site ImportSite
+	rule LayerRule
+	deny string
+}
+
+const DefaultLayerRulesFile = ".go-ci-layers.json"
+
+// LoadLayerRules reads the layering rules, a missing file means
+// no rules unless `required` is set
+func LoadLayerRules(rulesFile string, required bool) (LayerRules, error) {
+	rules := LayerRules{}
+
+	//nolint:gosec // Rules file given by the user
+	content, err := os.ReadFile(rulesFile)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return rules, nil
+	}
+
+	if err != nil {
+		return rules, fmt.Errorf("unable to read layering rules %s: %s", rulesFile, err.Error())
+	}
+
+	err = json.Unmarshal(content, &rules)
+	if err != nil {
+		return rules, fmt.Errorf("unable to parse layering rules %s: %s", rulesFile, err.Error())
+	}
+
+	for _, r := range rules.Rules {
+		for _, pattern := range append([]string{r.From}, r.Deny...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return rules, fmt.Errorf("invalid pattern %q in layering rules %s", pattern, rulesFile)
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// matchModuleDir returns true if the module directory or one of its parents
+// matches the pattern, so `libs/*` also matches `libs/a/b`
+func matchModuleDir(pattern string, dir string) bool {
+	for {
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+
+		parent := path.Dir(dir)
+		if parent == dir || parent == "." {
+			return false
+		}
+
+		dir = parent
+	}
+}
+
+func findLayerViolations(graph RepoGraph, rules LayerRules) []layerViolation {
+	dirs := make(map[string]string)
+	for _, m := range graph.Modules {
+		dirs[m.Path] = m.Dir
+	}
+
+	violations := make([]layerViolation, 0)
+
+	for _, site := range graph.Imports {
+		for _, rule := range rules.Rules {
+			if !matchModuleDir(rule.From, dirs[site.FromModule]) {
+				continue
+			}
+
+			for _, deny := range rule.Deny {
+				if matchModuleDir(deny, dirs[site.ToModule]) {
+					violations = append(violations, layerViolation{site: site, rule: rule, deny: deny})
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+func printImportSite(site ImportSite) {
+	color.Printf(color.MutedColor, "    %s:%d: %s imports %s\n", site.File, site.Line, site.Package, site.Import)
+}
+
+// CheckRepoDependencies checks the modules under `root` for cycles in their
+// go.mod requirements and package imports, and for layering rule violations
+func CheckRepoDependencies(root string, rules LayerRules) error {
+	graph, err := BuildRepoGraph(root)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+
+	for _, cycle := range FindCycles(graph.ModulePaths(), graph.Requires) {
+		problems++
+
+		color.Printf(color.ErrorColorBold, "Module requirement cycle between %s\n", strings.Join(cycle, ", "))
+
+		for _, from := range cycle {
+			for _, to := range graph.Requires[from] {
+				if slices.Contains(cycle, to) {
+					color.Printf(color.MutedColor, "    %s requires %s\n", from, to)
+				}
+			}
+		}
+	}
+
+	for _, cycle := range FindCycles(graph.ModulePaths(), graph.ImportEdges()) {
+		problems++
+
+		color.Printf(color.ErrorColorBold, "Import cycle between modules %s\n", strings.Join(cycle, ", "))
+
+		inCycle := make(map[string]bool)
+		for _, m := range cycle {
+			inCycle[m] = true
+		}
+
+		for _, site := range graph.Imports {
+			if inCycle[site.FromModule] && inCycle[site.ToModule] {
+				printImportSite(site)
+			}
+		}
+	}
+
+	for _, v := range findLayerViolations(graph, rules) {
+		problems++
+
+		color.Printf(
+			color.ErrorColorBold,
+			"Layering violation: %s may not import %s (%s imports %s)\n",
+			v.rule.From,
+			v.deny,
+			v.site.FromModule,
+			v.site.ToModule,
+		)
+
+		if v.rule.Reason != "" {
+			color.Printf(color.WarningColor, "    %s\n", v.rule.Reason)
+		}
+
+		printImportSite(v.site)
+	}
+
+	if problems > 0 {
+		color.Printf(color.ErrorColorBold, "\nFound %d dependency problems between %d modules\n", problems, len(graph.Modules))
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"No cycles or layering violations between %d modules and %d cross-module imports :)\n",
+		len(graph.Modules),
+		len(graph.Imports),
+	)
+
+	return nil
+}
+
+func GetCheckDependenciesCommand() *cobra.Command {
+	const checkDepsLongHelpDesc = `
+Check the Go modules in current or sub-directories for cycles in their go.mod requirements on each other
+and in package imports between them, including cycles only possible across versions.
+Layering rules are read from .go-ci-layers.json in the current directory if present, e.g.:
+
+  {"rules": [{"from": "libs/*", "deny": ["services/*"], "reason": "Libraries must not depend on services"}]}
+
+Patterns match module directories relative to the current directory, or any of their parents.
+`
+
+	const RulesFlag = "rules"
+
+	checkDepsCommand := &cobra.Command{
+		Use: "check-deps",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			rulesFile, err := cmd.Flags().GetString(RulesFlag)
+			if err != nil {
+				return err
+			}
+
+			rules, err := LoadLayerRules(filepath.Join(cwd, DefaultLayerRulesFile), false)
+			if cmd.Flags().Changed(RulesFlag) {
+				rules, err = LoadLayerRules(rulesFile, true)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			return CheckRepoDependencies(cwd, rules)
+		},
+		Args: cobra.NoArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Check for cycles and layering violations between modules",
+		Long:                  checkDepsLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	checkDepsCommand.Flags().
+		String(RulesFlag, DefaultLayerRulesFile, "JSON file with the layering rules")
+
+	err := checkDepsCommand.MarkFlagFilename(RulesFlag, "json")
+	if err != nil {
+		panic(err)
+	}
+
+	return checkDepsCommand
+}
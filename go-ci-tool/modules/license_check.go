@@ -0,0 +1,639 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+)
+
+// LicensePolicy decides which dependency licenses are acceptable
+type LicensePolicy struct {
+	// SPDX identifiers that are allowed, `*` wildcards are supported.
+	// Every other license is a violation if the list is not empty.
+	Allow []string `json:"allow"`
+	// SPDX identifiers that are never allowed, `*` wildcards are supported
+	Deny []string `json:"deny"`
+	// Accept dependencies whose license couldn't be detected
+	AllowUnknown bool `json:"allow_unknown"`
+	// Module paths excluded from the check, e.g. internal modules
+	Ignore []string `json:"ignore"`
+}
+
+type LicenseStatus string
+
+// DependencyLicense is one row of the license report
+type DependencyLicense struct {
+	Module   string        `json:"module"`
+	Version  string        `json:"version"`
+	Licenses []string      `json:"licenses"`
+	Files    []string      `json:"files"`
+	Status   LicenseStatus `json:"status"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+// Subset of `go list -m -json` output
+type listedModule struct {
+	Path    string
+	Version string
+	Dir     string
+	Main    bool
+	Replace *listedModule
+}
+
+// Module as printed by 'go mod download -json'
+type downloadedModule struct {
+	Path    string
+	Version string
+	Dir     string
+	Error   string
+}
+
+// Phrases identifying a license in normalized text, all must be present.
+// Titles must be near the start as license texts refer to other licenses.
+type licenseMatcher struct {
+	id      string
+	titles  []string
+	phrases []string
+}
+
+const (
+	LicenseAllowed       LicenseStatus = "allowed"
+	LicenseDenied        LicenseStatus = "denied"
+	LicenseUnknown       LicenseStatus = "unknown"
+	LicenseIgnored       LicenseStatus = "ignored"
+	LicenseNotDownloaded LicenseStatus = "not-downloaded"
+)
+
+const (
+	// Policy looked up in the module root if none is provided
+	DefaultLicensePolicyFile = ".license-policy.json"
+	unknownLicense           = "Unknown"
+	// Words at the start of a license text searched for its title,
+	// enough to skip a copyright header
+	licenseTitleWords = 150
+	reportFilePerm    = 0o644
+	reportDirPerm     = 0o755
+)
+
+// licenseMatchers are checked in order, more specific licenses first
+// (e.g. AGPL and LGPL notices also mention the GPL)
+//
+//nolint:gochecknoglobals // Constant table of license phrases
+var licenseMatchers = []licenseMatcher{
+	{"MPL-2.0", []string{"mozilla public license", "version 2 0"}, nil},
+	{"EPL-2.0", []string{"eclipse public license", "v 2 0"}, nil},
+	{"Apache-2.0", []string{"apache license", "version 2 0"}, nil},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}, nil},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}, nil},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2 1"}, nil},
+	{"LGPL-2.0", []string{"gnu library general public license", "version 2"}, nil},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}, nil},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}, nil},
+	{"BSD-3-Clause", nil, []string{
+		"redistribution and use in source and binary forms",
+		"neither the name of",
+	}},
+	{"BSD-2-Clause", nil, []string{
+		"redistribution and use in source and binary forms",
+		"this software is provided by the copyright holders and contributors as is",
+	}},
+	{"MIT", nil, []string{
+		"permission is hereby granted free of charge to any person obtaining a copy",
+		"the above copyright notice and this permission notice shall be included",
+	}},
+	{"ISC", nil, []string{
+		"permission to use copy modify and or distribute this software for any purpose",
+	}},
+	{"Unlicense", nil, []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1 0 universal"}, nil},
+}
+
+// DefaultLicensePolicy denies copyleft licenses that affect services
+func DefaultLicensePolicy() LicensePolicy {
+	return LicensePolicy{
+		Deny: []string{"GPL-*", "AGPL-*"},
+	}
+}
+
+// LoadLicensePolicy reads the policy file
+func LoadLicensePolicy(policyFile string) (LicensePolicy, error) {
+	policy := LicensePolicy{}
+
+	//nolint:gosec // Policy path provided by the user
+	content, err := os.ReadFile(policyFile)
+	if err != nil {
+		return policy, fmt.Errorf("unable to read license policy %s: %s", policyFile, err.Error())
+	}
+
+	err = json.Unmarshal(content, &policy)
+	if err != nil {
+		return policy, fmt.Errorf("unable to parse license policy %s: %s", policyFile, err.Error())
+	}
+
+	for _, pattern := range slices.Concat(policy.Allow, policy.Deny) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return policy, fmt.Errorf("invalid pattern %q in license policy %s", pattern, policyFile)
+		}
+	}
+
+	return policy, nil
+}
+
+// isLicenseFile reports whether the file name looks like a license file,
+// e.g. LICENSE, LICENSE.md, LICENSE-MIT, COPYING, LICENCE.txt
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeLicenseText lower cases the text and splits it into words,
+// dropping punctuation
+func normalizeLicenseText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func containsAll(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ClassifyLicense returns the SPDX identifier of the license text,
+// or "Unknown" if it is not recognized
+func ClassifyLicense(text string) string {
+	words := normalizeLicenseText(text)
+	normalized := strings.Join(words, " ")
+	head := strings.Join(words[:min(len(words), licenseTitleWords)], " ")
+
+	for _, m := range licenseMatchers {
+		if containsAll(head, m.titles) && containsAll(normalized, m.phrases) {
+			return m.id
+		}
+	}
+
+	return unknownLicense
+}
+
+// detectLicenses classifies the license files in the module root directory
+func detectLicenses(dir string) ([]string, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	licenses := make([]string, 0)
+	files := make([]string, 0)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isLicenseFile(entry.Name()) {
+			continue
+		}
+
+		//nolint:gosec // License file inside the module cache
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files = append(files, entry.Name())
+
+		license := ClassifyLicense(string(content))
+		if !slices.Contains(licenses, license) {
+			licenses = append(licenses, license)
+		}
+	}
+
+	return licenses, files, nil
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// evaluate sets the status of the dependency. All detected licenses must
+// be acceptable, as it is not known which one of multiple licenses applies.
+func (p LicensePolicy) evaluate(dep *DependencyLicense) {
+	if matchesAny(p.Ignore, dep.Module) {
+		dep.Status = LicenseIgnored
+		return
+	}
+
+	if len(dep.Licenses) == 0 {
+		dep.Licenses = []string{unknownLicense}
+		dep.Reason = "no license file found"
+	}
+
+	for _, license := range dep.Licenses {
+		if license == unknownLicense {
+			continue
+		}
+
+		if matchesAny(p.Deny, license) {
+			dep.Status = LicenseDenied
+			dep.Reason = "license " + license + " is denied"
+			return
+		}
+
+		if len(p.Allow) > 0 && !matchesAny(p.Allow, license) {
+			dep.Status = LicenseDenied
+			dep.Reason = "license " + license + " is not allowed"
+			return
+		}
+	}
+
+	if slices.Contains(dep.Licenses, unknownLicense) {
+		dep.Status = LicenseUnknown
+		if dep.Reason == "" {
+			dep.Reason = "license not recognized"
+		}
+		return
+	}
+
+	dep.Status = LicenseAllowed
+}
+
+// listBuildList returns the modules in the build list of the module
+func listBuildList(details ModuleDetails) ([]listedModule, error) {
+	// Read only, so that the go.sum of the module is never updated
+	cmd := exec.Command(GO, "list", "-mod=readonly", "-m", "-json", "all")
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'go list -m all' for module %s, error: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Print(color.MutedColor, errOut.String())
+		return nil, fmt.Errorf("'go list -m all' failed for module %s", details.Module)
+	}
+
+	modules := make([]listedModule, 0)
+	decoder := json.NewDecoder(&out)
+
+	for {
+		m := listedModule{}
+
+		err := decoder.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'go list -m' output: %s", err.Error())
+		}
+
+		modules = append(modules, m)
+	}
+
+	return modules, nil
+}
+
+// downloadModules downloads the given module versions (path@version) to the
+// module cache, so that their licenses can be read. Downloading explicit versions
+// never changes the go.sum of the module, unlike 'go mod download all'.
+// Returns the directories of the downloaded versions, versions that can't be
+// downloaded are left out.
+func downloadModules(
+	details ModuleDetails,
+	versions []string,
+) (map[string]string, error) {
+	dirs := make(map[string]string)
+
+	if len(versions) == 0 {
+		return dirs, nil
+	}
+
+	args := append([]string{"mod", "download", "-json"}, versions...)
+
+	cmd := exec.Command(GO, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'go mod download' for module %s, error: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	// Failed downloads are reported in the output with a non zero exit code
+	decoder := json.NewDecoder(&out)
+
+	for {
+		m := downloadedModule{}
+
+		err := decoder.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			color.Print(color.MutedColor, errOut.String())
+			return nil, fmt.Errorf("'go mod download' failed for module %s", details.Module)
+		}
+
+		if m.Error == "" && m.Dir != "" {
+			dirs[m.Path+"@"+m.Version] = m.Dir
+		}
+	}
+
+	return dirs, nil
+}
+
+// DependencyLicenses downloads and then detects and evaluates the licenses
+// of all the dependencies in the build list of the module
+func DependencyLicenses(
+	details ModuleDetails,
+	policy LicensePolicy,
+) ([]DependencyLicense, error) {
+	modules, err := listBuildList(details)
+	if err != nil {
+		return nil, err
+	}
+
+	// Modules without a directory are not in the module cache yet
+	missing := make([]string, 0)
+
+	for _, m := range modules {
+		source := m
+		if m.Replace != nil {
+			source = *m.Replace
+		}
+
+		if !m.Main && source.Dir == "" && source.Version != "" {
+			missing = append(missing, source.Path+"@"+source.Version)
+		}
+	}
+
+	downloaded, err := downloadModules(details, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make([]DependencyLicense, 0, len(modules))
+
+	for _, m := range modules {
+		if m.Main {
+			continue
+		}
+
+		dep := DependencyLicense{
+			Module:   m.Path,
+			Version:  m.Version,
+			Licenses: []string{},
+			Files:    []string{},
+		}
+
+		source := m
+		if m.Replace != nil {
+			source = *m.Replace
+			if m.Replace.Version != "" {
+				dep.Version = m.Replace.Version
+			}
+		}
+
+		dir := source.Dir
+		if dir == "" {
+			dir = downloaded[source.Path+"@"+source.Version]
+		}
+
+		if dir == "" {
+			dep.Status = LicenseNotDownloaded
+			dep.Reason = "module source is not available"
+
+			if matchesAny(policy.Ignore, dep.Module) {
+				dep.Status = LicenseIgnored
+			}
+
+			deps = append(deps, dep)
+
+			continue
+		}
+
+		dep.Licenses, dep.Files, err = detectLicenses(dir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read licenses of %s: %s", m.Path, err.Error())
+		}
+
+		policy.evaluate(&dep)
+		deps = append(deps, dep)
+	}
+
+	return deps, nil
+}
+
+func writeLicenseCSV(file string, deps []DependencyLicense) error {
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{{"module", "version", "licenses", "files", "status", "reason"}}
+
+	for _, dep := range deps {
+		records = append(records, []string{
+			dep.Module,
+			dep.Version,
+			strings.Join(dep.Licenses, ";"),
+			strings.Join(dep.Files, ";"),
+			string(dep.Status),
+			dep.Reason,
+		})
+	}
+
+	err := w.WriteAll(records)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), reportFilePerm)
+}
+
+func writeLicenseJSON(
+	file string,
+	details ModuleDetails,
+	deps []DependencyLicense,
+) error {
+	report := struct {
+		Module       string              `json:"module"`
+		Dependencies []DependencyLicense `json:"dependencies"`
+	}{
+		Module:       details.Module,
+		Dependencies: deps,
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, append(out, '\n'), reportFilePerm)
+}
+
+// writeLicenseReport writes <module>-licenses.csv and <module>-licenses.json
+// into the directory, with `/` in the module path replaced by `_`
+func writeLicenseReport(
+	dir string,
+	details ModuleDetails,
+	deps []DependencyLicense,
+) error {
+	err := os.MkdirAll(dir, reportDirPerm)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(dir, strings.ReplaceAll(details.Module, "/", "_")+"-licenses")
+
+	err = writeLicenseCSV(base+".csv", deps)
+	if err != nil {
+		return err
+	}
+
+	err = writeLicenseJSON(base+".json", details, deps)
+	if err != nil {
+		return err
+	}
+
+	color.Printf(color.InfoColor, "License report written to %s.csv and %s.json\n", base, base)
+
+	return nil
+}
+
+// CheckModuleLicenses fails if any dependency of the module has a license
+// not accepted by the policy, optionally writing a license report
+func CheckModuleLicenses(
+	details ModuleDetails,
+	policyPath string,
+	reportDir string,
+) error {
+	color.Printf(color.InfoColor, "Checking licenses of dependencies of go module %s\n", details.Module)
+
+	policy := DefaultLicensePolicy()
+
+	if policyPath == "" {
+		defaultPath := filepath.Join(details.ModulePath, DefaultLicensePolicyFile)
+		if _, err := os.Stat(defaultPath); err == nil {
+			policyPath = defaultPath
+		}
+	}
+
+	if policyPath != "" {
+		var err error
+
+		policy, err = LoadLicensePolicy(policyPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	deps, err := DependencyLicenses(details, policy)
+	if err != nil {
+		return err
+	}
+
+	if reportDir != "" {
+		err = writeLicenseReport(reportDir, details, deps)
+		if err != nil {
+			return fmt.Errorf("unable to write license report: %s", err.Error())
+		}
+	}
+
+	violations := 0
+
+	for _, dep := range deps {
+		line := fmt.Sprintf(
+			"%s@%s: %s (%s)",
+			dep.Module,
+			dep.Version,
+			strings.Join(dep.Licenses, ", "),
+			dep.Status,
+		)
+
+		// Licenses of modules that couldn't be downloaded are not known to be allowed
+		switch {
+		case dep.Status == LicenseDenied,
+			dep.Status == LicenseNotDownloaded,
+			dep.Status == LicenseUnknown && !policy.AllowUnknown:
+			violations++
+
+			color.Printf(color.ErrorColor, "%s %s\n", line, dep.Reason)
+		case dep.Status == LicenseUnknown:
+			color.Printf(color.WarningColor, "%s %s\n", line, dep.Reason)
+		default:
+			color.Println(color.MutedColor, line)
+		}
+	}
+
+	color.Println(color.NoColor)
+
+	if violations > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"%d dependencies of go module %s violate the license policy\n",
+			violations,
+			details.Module,
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Licenses of all dependencies of go module %s are allowed :)\n",
+		details.Module,
+	)
+
+	return nil
+}
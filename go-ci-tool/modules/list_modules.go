@@ -0,0 +1,200 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ModuleInfo is the metadata of a module listed by `list-modules --details`
+type ModuleInfo struct {
+	Dir       string        `json:"dir"`
+	Module    string        `json:"module"`
+	GoVersion string        `json:"goVersion"`
+	Toolchain string        `json:"toolchain,omitempty"`
+	Replaces  []ReplaceInfo `json:"replaces"`
+	// Modules of the repository required in go.mod
+	RepoDependencies []string `json:"repoDependencies"`
+	Packages         int      `json:"packages"`
+	HasTests         bool     `json:"hasTests"`
+	HasMain          bool     `json:"hasMain"`
+}
+
+// ModuleFilter selects modules matching all of the set conditions
+type ModuleFilter struct {
+	// Patterns matched against the module directory, any of them must match
+	Globs []string
+	// Patterns matched against the module path, any of them must match
+	PathPatterns []string
+	// Git revision, only modules with files changed since it are selected
+	ChangedSince string
+}
+
+func matchAny(patterns []string, value string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, value)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %s", pattern, err.Error())
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// changedFiles returns the tracked files changed since `rev` and the untracked
+// files, relative to `dir` and limited to it
+func changedFiles(dir string, rev string) ([]string, error) {
+	// NUL separated output keeps paths with spaces and non-ASCII characters unquoted
+	changed, err := gitOutput(dir, "diff", "-z", "--name-only", "--relative", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := gitOutput(dir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+
+	for file := range strings.SplitSeq(string(changed)+string(untracked), "\x00") {
+		if file != "" {
+			files = append(files, filepath.FromSlash(file))
+		}
+	}
+
+	return files, nil
+}
+
+// FilterModules returns the module directories under `root` matching the filter
+func FilterModules(root string, dirs []string, filter ModuleFilter) ([]string, error) {
+	changed := make(map[string]bool)
+
+	if filter.ChangedSince != "" {
+		files, err := changedFiles(root, filter.ChangedSince)
+		if err != nil {
+			return nil, err
+		}
+
+		idx, err := newModuleIndex(root, dirs)
+		if err != nil {
+			return nil, err
+		}
+
+		// Files of removed modules must not select their parent module
+		for _, file := range files {
+			if filepath.Base(file) != GoMod {
+				continue
+			}
+
+			if _, err := os.Stat(filepath.Join(root, file)); errors.Is(err, os.ErrNotExist) {
+				idx.Deleted = append(idx.Deleted, filepath.Dir(file))
+			}
+		}
+
+		for _, dir := range idx.ModulesForFiles(files) {
+			changed[dir] = true
+		}
+	}
+
+	selected := make([]string, 0, len(dirs))
+
+	for _, dir := range dirs {
+		if filter.ChangedSince != "" && !changed[dir] {
+			continue
+		}
+
+		if len(filter.Globs) > 0 {
+			ok, err := matchAny(filter.Globs, filepath.ToSlash(dir))
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		if len(filter.PathPatterns) > 0 {
+			details, err := GetDetailsForModFile(filepath.Join(root, dir))
+			if err != nil {
+				return nil, err
+			}
+
+			ok, err := matchAny(filter.PathPatterns, details.Module)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		selected = append(selected, dir)
+	}
+
+	return selected, nil
+}
+
+// GetModuleInfo returns the metadata of the module in `dir` under `root`,
+// `repoModules` are the paths of all modules of the repository
+func GetModuleInfo(root string, dir string, repoModules []string) (ModuleInfo, error) {
+	details, err := GetDetailsForModFile(filepath.Join(root, dir))
+	if err != nil {
+		return ModuleInfo{}, err
+	}
+
+	info := ModuleInfo{
+		Dir:              filepath.ToSlash(dir),
+		Module:           details.Module,
+		GoVersion:        details.GoVersion,
+		Toolchain:        details.Toolchain,
+		Replaces:         details.Replaces,
+		RepoDependencies: make([]string, 0),
+	}
+
+	for _, r := range details.Requires {
+		if slices.Contains(repoModules, r.Path) {
+			info.RepoDependencies = append(info.RepoDependencies, r.Path)
+		}
+	}
+
+	packageDirs := make(map[string]bool)
+
+	err = walkModuleGoFiles(details.ModulePath, func(file string) error {
+		if strings.HasSuffix(file, "_test.go") {
+			info.HasTests = true
+			return nil
+		}
+
+		packageDirs[filepath.Dir(file)] = true
+
+		if info.HasMain {
+			return nil
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return fmt.Errorf("unable to parse %s: %s", file, err.Error())
+		}
+
+		info.HasMain = f.Name.Name == "main"
+
+		return nil
+	})
+
+	info.Packages = len(packageDirs)
+
+	return info, err
+}
@@ -0,0 +1,350 @@
+package modules
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// importRewriter moves imports of a module path to a new path, except imports
+// of other repository modules nested under the old path
+type importRewriter struct {
+	oldPath string
+	newPath string
+	nested  []string
+}
+
+// rewrite returns the new import path and true if the import belongs to the module
+func (r importRewriter) rewrite(importPath string) (string, bool) {
+	if importPath == r.newPath || strings.HasPrefix(importPath, r.newPath+"/") {
+		return "", false
+	}
+
+	rest, ok := strings.CutPrefix(importPath, r.oldPath)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+
+	for _, n := range r.nested {
+		if importPath == n || strings.HasPrefix(importPath, n+"/") {
+			return "", false
+		}
+	}
+
+	return r.newPath + rest, true
+}
+
+// rewriteFile rewrites the import paths of the file in place, keeping the
+// rest of the content untouched. Returns true if the file changed.
+func (r importRewriter) rewriteFile(file string, dryRun bool) (bool, error) {
+	//nolint:gosec // Go file inside a module of the repository
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+
+	f, err := parser.ParseFile(fset, file, content, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse %s: %s", file, err.Error())
+	}
+
+	updated := slices.Clone(content)
+	changed := false
+
+	// Replace from the last import so earlier offsets stay valid
+	for _, imp := range slices.Backward(f.Imports) {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return false, fmt.Errorf("invalid import %s in %s", imp.Path.Value, file)
+		}
+
+		newImport, ok := r.rewrite(importPath)
+		if !ok {
+			continue
+		}
+
+		start := fset.Position(imp.Path.Pos()).Offset
+		end := fset.Position(imp.Path.End()).Offset
+
+		updated = slices.Replace(updated, start, end, []byte(strconv.Quote(newImport))...)
+		changed = true
+	}
+
+	if !changed || dryRun {
+		return changed, nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(file, updated, info.Mode().Perm())
+}
+
+// rewriteModuleImports rewrites the imports in the Go files of the module in `dir`
+// and returns the files that changed
+func (r importRewriter) rewriteModuleImports(
+	dir string,
+	dryRun bool,
+) ([]string, error) {
+	changed := make([]string, 0)
+
+	err := walkModuleGoFiles(dir, func(path string) error {
+		ok, err := r.rewriteFile(path, dryRun)
+		if ok {
+			changed = append(changed, path)
+		}
+		return err
+	})
+
+	return changed, err
+}
+
+func writeModFile(file string, f *modfile.File, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	f.Cleanup()
+
+	out, err := f.Format()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, out, readAllOwnerWritePerm)
+}
+
+// updateDependentModFile moves the require and replace directives of the old module
+// path to the new path. Returns false if the module doesn't depend on the old path.
+func updateDependentModFile(
+	dir string,
+	oldPath string,
+	newPath string,
+	newVersion string,
+	dryRun bool,
+) (bool, error) {
+	file := filepath.Join(dir, GoMod)
+
+	//nolint:gosec // go.mod of a module in the repository
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := modfile.Parse(file, content, nil)
+	if err != nil {
+		return false, err
+	}
+
+	idx := slices.IndexFunc(f.Require, func(r *modfile.Require) bool { return r.Mod.Path == oldPath })
+	if idx < 0 {
+		return false, nil
+	}
+
+	indirect := f.Require[idx].Indirect
+
+	err = f.DropRequire(oldPath)
+	if err != nil {
+		return false, err
+	}
+
+	f.AddNewRequire(newPath, newVersion, indirect)
+
+	for _, r := range slices.Clone(f.Replace) {
+		if r.Old.Path != oldPath {
+			continue
+		}
+
+		// DropReplace clears the directive, keep the replacement
+		replacement := r.New
+
+		err = f.DropReplace(r.Old.Path, r.Old.Version)
+		if err != nil {
+			return false, err
+		}
+
+		err = f.AddReplace(newPath, "", replacement.Path, replacement.Version)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, writeModFile(file, f, dryRun)
+}
+
+// NextMajorPath returns the module path with the major version suffix for `major`
+func NextMajorPath(modulePath string, major int) (string, error) {
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok || strings.HasPrefix(pathMajor, ".") {
+		return "", fmt.Errorf("module path %s doesn't support a /vN major version suffix", modulePath)
+	}
+
+	current := 1
+	if pathMajor != "" {
+		current, _ = strconv.Atoi(strings.TrimPrefix(pathMajor, "/v"))
+	}
+
+	if major == 0 {
+		major = current + 1
+	}
+
+	if major <= current {
+		return "", fmt.Errorf("major version %d must be greater than the current major version %d of %s", major, current, modulePath)
+	}
+
+	return fmt.Sprintf("%s/v%d", prefix, major), nil
+}
+
+// BumpMajorVersion moves the module to the next major version (or `major` if set):
+// the module line of go.mod gets the /vN suffix and all imports inside the module are
+// rewritten. With `updateDependents` other modules of the repository requiring it
+// are moved to the new path too.
+func BumpMajorVersion(
+	details ModuleDetails,
+	major int,
+	updateDependents bool,
+	dryRun bool,
+) error {
+	newPath, err := NextMajorPath(details.Module, major)
+	if err != nil {
+		return err
+	}
+
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return err
+	}
+
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return err
+	}
+
+	others := make(map[string]ModuleDetails)
+	rewriter := importRewriter{oldPath: details.Module, newPath: newPath}
+
+	for _, m := range modules {
+		if m == relDir {
+			continue
+		}
+
+		other, err := GetDetailsForModFile(filepath.Join(root, m))
+		if err != nil {
+			return err
+		}
+
+		others[m] = other
+
+		if strings.HasPrefix(other.Module, details.Module+"/") {
+			rewriter.nested = append(rewriter.nested, other.Module)
+		}
+	}
+
+	color.Printf(color.InfoColor, "Moving go module %s to %s\n", details.Module, newPath)
+
+	goModFile := filepath.Join(details.ModulePath, GoMod)
+
+	//nolint:gosec // go.mod of the module
+	content, err := os.ReadFile(goModFile)
+	if err != nil {
+		return err
+	}
+
+	f, err := modfile.Parse(goModFile, content, nil)
+	if err != nil {
+		return err
+	}
+
+	err = f.AddModuleStmt(newPath)
+	if err != nil {
+		return err
+	}
+
+	err = writeModFile(goModFile, f, dryRun)
+	if err != nil {
+		return err
+	}
+
+	changed, err := rewriter.rewriteModuleImports(details.ModulePath, dryRun)
+	if err != nil {
+		return err
+	}
+
+	changed = append([]string{goModFile}, changed...)
+
+	// First release of the new major version, e.g. v2.0.0
+	newVersion := newPath[strings.LastIndex(newPath, "/")+1:] + ".0.0"
+
+	if updateDependents {
+		for _, m := range modules {
+			other, ok := others[m]
+			if !ok {
+				continue
+			}
+
+			depends, err := updateDependentModFile(other.ModulePath, details.Module, newPath, newVersion, dryRun)
+			if err != nil {
+				return fmt.Errorf("unable to update go.mod of module %s: %s", other.Module, err.Error())
+			}
+
+			if !depends {
+				continue
+			}
+
+			color.Printf(color.InfoColor, "Updating dependent go module %s\n", other.Module)
+
+			files, err := rewriter.rewriteModuleImports(other.ModulePath, dryRun)
+			if err != nil {
+				return err
+			}
+
+			changed = append(changed, filepath.Join(other.ModulePath, GoMod))
+			changed = append(changed, files...)
+		}
+	}
+
+	for _, file := range changed {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			rel = file
+		}
+
+		color.Printf(color.MutedColor, "%s\n", rel)
+	}
+
+	if dryRun {
+		color.Printf(color.InfoColorBold, "Would change %d files to move %s to %s\n", len(changed), details.Module, newPath)
+		return nil
+	}
+
+	color.Printf(color.SuccessColorBold, "Changed %d files to move %s to %s :)\n", len(changed), details.Module, newPath)
+
+	if updateDependents {
+		color.Printf(
+			color.WarningColor,
+			"Dependent modules require %s at %s, tag the release or use go.work/replace before building them\n",
+			newPath,
+			newVersion,
+		)
+	}
+
+	return nil
+}
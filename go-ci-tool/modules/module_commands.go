@@ -23,6 +23,7 @@
 	AllModulesPath = "./..."
 	GolangCILint   = "golangci-lint"
 	GO             = "go"
+	GIT            = "git"
 	GoWorkOff      = "GOWORK=off"
 )
 
@@ -63,9 +64,7 @@ func CheckReplaceIsNotLocal(details ModuleDetails) error {
 	valid := true
 
 	for _, info := range details.Replaces {
-		if info.NewPath == "." || info.NewPath == ".." ||
-			strings.HasPrefix(info.NewPath, "./") ||
-			strings.HasPrefix(info.NewPath, "../") {
+		if isLocalPath(info.NewPath) {
 			color.Printf(color.ErrorColor,
 				"Go module %s is using replace directive with local path '%s'.\n",
 				details.Module,
@@ -198,10 +197,18 @@ func RunGolangCILintFmt(details ModuleDetails) error {
 	}
 }
 
-func RunGolangCILintFix(details ModuleDetails) error {
+// RunGolangCILintFix applies the auto-fixes for lint issues in the module.
+// If `linters` is not empty, only fixes from those linters are applied.
+func RunGolangCILintFix(details ModuleDetails, linters []string) error {
 	color.Println(color.InfoColor, "golanlangci-lint run --fix ./...")
 
-	args := []string{"run", "--fix", AllModulesPath}
+	args := []string{"run", "--fix"}
+
+	if len(linters) > 0 {
+		args = append(args, "--enable-only", strings.Join(linters, ","))
+	}
+
+	args = append(args, AllModulesPath)
 
 	cmd := exec.Command(GolangCILint, args...)
 	cmd.Dir = details.ModulePath
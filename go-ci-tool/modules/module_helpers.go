@@ -1,35 +1,73 @@
 package modules
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
 
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
 	"golang.org/x/mod/modfile"
 )
 
 type ReplaceInfo struct {
-	OldPath    string
-	NewPath    string
-	OldVersion string
-	NewVersion string
+	OldPath    string `json:"oldPath"`
+	NewPath    string `json:"newPath"`
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+}
+
+type RequireInfo struct {
+	Path     string
+	Version  string
+	Indirect bool
 }
 
 type ModuleDetails struct {
 	Module     string
 	ModulePath string
 	GoVersion  string
+	Toolchain  string
 	Replaces   []ReplaceInfo
+	Requires   []RequireInfo
+	// Package paths of tools declared with `tool` directives
+	Tools []string
+}
+
+// ModuleSearchOptions controls which directories are searched for modules,
+// read by FindAllModules from ModuleSearchConfigFile of the repository root
+type ModuleSearchOptions struct {
+	// Patterns of directories to skip, matched against the directory name and
+	// its path relative to Root, e.g. `build` or `examples/*`
+	Exclude []string `json:"exclude"`
+	// Also return modules in directories ignored by git
+	IncludeIgnored bool `json:"includeIgnored"`
+	// Absolute path the exclude patterns are relative to,
+	// the searched directory if empty
+	Root string `json:"-"`
 }
 
 const (
 	NotAbsolutePathError = "dir must be an absolute path"
 	GoMod                = "go.mod"
+	GoSum                = "go.sum"
+	// Module search options of a repository, see ModuleSearchOptions
+	ModuleSearchConfigFile = ".go-ci-modules.json"
+	// Exit code of git outside of a git work tree
+	gitFatalExitCode = 128
 )
 
+// Directories never searched for modules, besides hidden directories
+//
+//nolint:gochecknoglobals // Variable exported to be used
+var DefaultExcludedDirs = []string{"node_modules", "vendor", "testdata"}
+
 // FindModuleRoot for the given directory, assuming it is a Go module
 // `dir` must be an absolute path
 // Returns relative path to the module root
@@ -66,43 +104,326 @@ func FindModuleRoot(dir string) (string, error) {
 	return "", errors.New("not inside a go module")
 }
 
+func (o ModuleSearchOptions) excluded(relPath string, name string) (bool, error) {
+	if strings.HasPrefix(name, ".") || slices.Contains(DefaultExcludedDirs, name) {
+		return true, nil
+	}
+
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range o.Exclude {
+		for _, value := range []string{name, relPath} {
+			ok, err := path.Match(pattern, value)
+			if err != nil {
+				return false, fmt.Errorf("invalid exclude pattern %q: %s", pattern, err.Error())
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// excludedDir returns true if the directory, given relative to the root,
+// or any of its parents below the root is excluded
+func (o ModuleSearchOptions) excludedDir(relDir string) (bool, error) {
+	for d := filepath.Clean(relDir); d != "."; d = filepath.Dir(d) {
+		skip, err := o.excluded(d, filepath.Base(d))
+		if err != nil || skip {
+			return skip, err
+		}
+	}
+
+	return false, nil
+}
+
+// rootRelDir returns `dir` relative to the root of the exclude patterns
+func (o ModuleSearchOptions) rootRelDir(dir string) (string, error) {
+	if o.Root == "" {
+		return ".", nil
+	}
+
+	root, err := filepath.EvalSymlinks(o.Root)
+	if err != nil {
+		return "", err
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, realDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside of %s", dir, o.Root)
+	}
+
+	return rel, nil
+}
+
+// gitWorkTreeOutput runs git in `dir` and returns its standard output,
+// false if git is missing or `dir` is not inside a git work tree
+func gitWorkTreeOutput(dir string, args ...string) ([]byte, bool, error) {
+	cmd := exec.Command(GIT, args...)
+	cmd.Dir = dir
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	//nolint:errcheck // Exit code is checked below
+	cmd.Run()
+
+	// Command failed to run, git is not available
+	if cmd.ProcessState == nil {
+		return nil, false, nil
+	}
+
+	switch cmd.ProcessState.ExitCode() {
+	case 0:
+		return out.Bytes(), true, nil
+	case gitFatalExitCode:
+		// Not a git work tree
+		return nil, false, nil
+	default:
+		color.Print(color.MutedColor, errOut.String())
+		return nil, false, fmt.Errorf("'git %s' failed in %s", strings.Join(args, " "), dir)
+	}
+}
+
+// ModuleSearchRoot returns the root of the git work tree containing `dir`,
+// or `dir` itself if it isn't inside a git work tree
+func ModuleSearchRoot(dir string) (string, error) {
+	out, ok, err := gitWorkTreeOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil || !ok {
+		return dir, err
+	}
+
+	return filepath.Clean(strings.TrimSpace(string(out))), nil
+}
+
+// sortModulesNestedFirst sorts module directories deepest first, so that
+// sub-modules are listed before their parent modules, then by name
+func sortModulesNestedFirst(modules []string) {
+	depth := func(m string) int {
+		if m == "." {
+			return 0
+		}
+
+		return strings.Count(filepath.ToSlash(m), "/") + 1
+	}
+
+	slices.SortFunc(modules, func(a, b string) int {
+		if d := depth(b) - depth(a); d != 0 {
+			return d
+		}
+
+		return strings.Compare(a, b)
+	})
+}
+
+// LoadModuleSearchOptions reads ModuleSearchConfigFile of `dir`,
+// the default options are returned if the file doesn't exist
+func LoadModuleSearchOptions(dir string) (ModuleSearchOptions, error) {
+	opts := ModuleSearchOptions{Root: dir}
+	file := filepath.Join(dir, ModuleSearchConfigFile)
+
+	//nolint:gosec // Config file of the repository
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return opts, nil
+	}
+
+	if err != nil {
+		return opts, fmt.Errorf(
+			"unable to read module search options %s: %s",
+			file,
+			err.Error(),
+		)
+	}
+
+	err = json.Unmarshal(content, &opts)
+	if err != nil {
+		return opts, fmt.Errorf(
+			"unable to parse module search options %s: %s",
+			file,
+			err.Error(),
+		)
+	}
+
+	for _, pattern := range opts.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return opts, fmt.Errorf("invalid exclude pattern %q in %s", pattern, file)
+		}
+	}
+
+	return opts, nil
+}
+
 // FindAllModules finds all subdirectories (including current dir)
-// that are a separate Go module
+// that are a separate Go module, with the search options of
+// ModuleSearchConfigFile in the root of the git work tree containing `dir`,
+// or in `dir` outside of git
 // `dir` must be an absolute path
-// Returns relative paths to the modules
+// Returns relative paths to the modules, sub-modules before their parents
 func FindAllModules(dir string) ([]string, error) {
 	if !filepath.IsAbs(dir) {
 		return nil, errors.New(NotAbsolutePathError)
 	}
 
-	dir = filepath.Clean(dir)
+	root, err := ModuleSearchRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := LoadModuleSearchOptions(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return FindModules(dir, opts)
+}
+
+// gitModules returns the modules under `dir` whose go.mod is tracked or
+// untracked but not ignored by git, relative to `dir`.
+// Returns false if `dir` isn't inside a git work tree.
+func gitModules(dir string) ([]string, bool, error) {
+	out, ok, err := gitWorkTreeOutput(
+		dir,
+		"ls-files", "-z", "--cached", "--others", "--exclude-standard", "--",
+		":(glob)**/"+GoMod,
+	)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
 
 	modules := make([]string, 0)
 
-	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+	for file := range strings.SplitSeq(string(out), "\x00") {
+		if file == "" {
+			continue
+		}
+
+		file = filepath.FromSlash(file)
+
+		// Tracked go.mod files may be deleted from the work tree
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			continue
+		}
+
+		if m := filepath.Dir(file); !slices.Contains(modules, m) {
+			modules = append(modules, m)
+		}
+	}
+
+	return modules, true, nil
+}
+
+// walkModules returns the modules under `dir` relative to it,
+// skipping excluded directories
+func walkModules(
+	dir string,
+	opts ModuleSearchOptions,
+	rootRel string,
+) ([]string, error) {
+	modules := make([]string, 0)
+
+	err := filepath.WalkDir(dir, func(current string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.Name() == GoMod {
-			relPath := strings.TrimPrefix(
-				strings.TrimPrefix(filepath.Clean(filepath.Dir(path)), dir),
-				string(filepath.Separator),
-			)
-			if relPath == "" {
-				relPath = "."
+		relPath, err := filepath.Rel(dir, current)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if current == dir {
+				return nil
+			}
+
+			skip, err := opts.excluded(filepath.Join(rootRel, relPath), d.Name())
+			if err != nil {
+				return err
+			}
+
+			if skip {
+				return filepath.SkipDir
 			}
-			modules = append(modules, relPath)
+
+			return nil
+		}
+
+		if d.Name() == GoMod {
+			modules = append(modules, filepath.Dir(relPath))
 		}
 
 		return nil
 	})
+
+	return modules, err
+}
+
+// FindModules finds all subdirectories (including current dir) that are a
+// separate Go module. Hidden directories, `node_modules`, `vendor`, `testdata`,
+// directories matching the exclude patterns and directories ignored by git
+// are skipped. Inside a git work tree ignored directories are not searched.
+// `dir` must be an absolute path
+// Returns relative paths to the modules, sub-modules before their parents
+func FindModules(dir string, opts ModuleSearchOptions) ([]string, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, errors.New(NotAbsolutePathError)
+	}
+
+	dir = filepath.Clean(dir)
+
+	rootRel, err := opts.rootRelDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The searched directory is inside of an excluded directory
+	skip, err := opts.excludedDir(rootRel)
+	if err != nil || skip {
+		return make([]string, 0), err
+	}
+
+	modules := make([]string, 0)
+	found := false
+
+	if !opts.IncludeIgnored {
+		modules, found, err = gitModules(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		modules, err = walkModules(dir, opts, rootRel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// git lists files of excluded directories as well
+	modules = slices.DeleteFunc(modules, func(m string) bool {
+		skip, matchErr := opts.excludedDir(filepath.Join(rootRel, m))
+		if matchErr != nil {
+			err = matchErr
+		}
+
+		return skip
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Reverse to make sure sub-modules are listed before their parent modules
-	slices.Reverse(modules)
+	sortModulesNestedFirst(modules)
 
 	return modules, nil
 }
@@ -136,6 +457,11 @@ func GetDetailsForModFile(dir string) (ModuleDetails, error) {
 	}
 
 	goVersion := f.Go.Version
+
+	toolchain := ""
+	if f.Toolchain != nil {
+		toolchain = f.Toolchain.Name
+	}
 	moduleName := f.Module.Mod.Path
 
 	replaces := make([]ReplaceInfo, 0)
@@ -152,10 +478,28 @@ func GetDetailsForModFile(dir string) (ModuleDetails, error) {
 		)
 	}
 
+	requires := make([]RequireInfo, 0, len(f.Require))
+
+	for _, r := range f.Require {
+		requires = append(
+			requires,
+			RequireInfo{Path: r.Mod.Path, Version: r.Mod.Version, Indirect: r.Indirect},
+		)
+	}
+
+	tools := make([]string, 0, len(f.Tool))
+
+	for _, t := range f.Tool {
+		tools = append(tools, t.Path)
+	}
+
 	return ModuleDetails{
 		Module:     moduleName,
 		ModulePath: dir,
 		GoVersion:  goVersion,
+		Toolchain:  toolchain,
 		Replaces:   replaces,
+		Requires:   requires,
+		Tools:      tools,
 	}, nil
 }
@@ -0,0 +1,100 @@
+package modules
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTestModules(t *testing.T, root string, dirs ...string) {
+	t.Helper()
+
+	for _, dir := range dirs {
+		writeTestFile(t, filepath.Join(root, dir, GoMod), "module example.com/"+dir+"\n")
+	}
+}
+
+func TestFindAllModulesInGitRepository(t *testing.T) {
+	if _, err := exec.LookPath(GIT); err != nil {
+		t.Skip("git is not available")
+	}
+
+	root := t.TempDir()
+
+	err := exec.Command(GIT, "init", "-q", root).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestModules(t, root, ".", "a", "a/b", "examples/e", "dist/x", ".hidden", "testdata/t")
+	writeTestFile(t, filepath.Join(root, ".gitignore"), "dist/\n")
+	writeTestFile(t, filepath.Join(root, ModuleSearchConfigFile), `{"exclude": ["examples/*"]}`)
+
+	tests := []struct {
+		name string
+		dir  string
+		want []string
+	}{
+		{"repository root", ".", []string{filepath.Join("a", "b"), "a", "."}},
+		{"sub-directory", "a", []string{"b", "."}},
+		{"inside excluded directory", "examples", []string{}},
+		{"inside ignored directory", "dist", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindAllModules(filepath.Join(root, tt.dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindAllModules(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+
+	writeTestFile(t, filepath.Join(root, ModuleSearchConfigFile), `{"includeIgnored": true}`)
+
+	got, err := FindAllModules(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join("a", "b"), filepath.Join("dist", "x"), filepath.Join("examples", "e"), "a", "."}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindAllModules with ignored modules = %q, want %q", got, want)
+	}
+}
+
+func TestFindModulesExcludePatterns(t *testing.T) {
+	root := t.TempDir()
+	writeTestModules(t, root, "a", "a/build", "b/build/x", "examples/e", "node_modules/p")
+
+	opts := ModuleSearchOptions{Exclude: []string{"build", "examples/*"}, Root: root}
+
+	got, err := FindModules(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a"}; !slices.Equal(got, want) {
+		t.Errorf("FindModules = %q, want %q", got, want)
+	}
+
+	// Patterns are relative to the root, not to the searched directory
+	got, err = FindModules(filepath.Join(root, "examples"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 0 {
+		t.Errorf("FindModules in an excluded directory = %q, want none", got)
+	}
+
+	_, err = FindModules(root, ModuleSearchOptions{Exclude: []string{"["}})
+	if err == nil {
+		t.Error("FindModules accepted an invalid pattern")
+	}
+}
@@ -0,0 +1,216 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// ModuleIndex maps files of a repository to the modules owning them
+type ModuleIndex struct {
+	// Absolute path the module directories are relative to
+	Root string
+	// Module directories, `.` for the root module, sub-modules before their parents
+	Modules []string
+	// Directories of modules whose go.mod was removed, e.g. since a compared revision.
+	// Their files aren't owned by the parent module.
+	Deleted []string
+	// Module directories used by the go.work file of the root, nil without go.work
+	Workspace []string
+}
+
+// FileModule is the module owning a file
+type FileModule struct {
+	// Module directory relative to the root, empty for workspace files
+	Dir string
+	// Dir is one of the deleted modules
+	Deleted bool
+	// The file is go.work or go.work.sum of the root, which affects
+	// all modules of the workspace instead of a single module
+	Workspace bool
+}
+
+const (
+	GoWork    = "go.work"
+	GoWorkSum = "go.work.sum"
+)
+
+// NewModuleIndex indexes the modules found under `root` by FindAllModules,
+// and the modules used by `root/go.work` if present
+// `root` must be an absolute path
+func NewModuleIndex(root string) (ModuleIndex, error) {
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return ModuleIndex{}, err
+	}
+
+	return newModuleIndex(root, modules)
+}
+
+func newModuleIndex(root string, modules []string) (ModuleIndex, error) {
+	if !filepath.IsAbs(root) {
+		return ModuleIndex{}, errors.New(NotAbsolutePathError)
+	}
+
+	idx := ModuleIndex{Root: filepath.Clean(root), Modules: slices.Clone(modules)}
+
+	workspace, err := workspaceModules(idx.Root)
+	if err != nil {
+		return idx, err
+	}
+
+	// Modules used by go.work may be in directories skipped by the search
+	for _, dir := range workspace {
+		if !slices.Contains(idx.Modules, dir) {
+			idx.Modules = append(idx.Modules, dir)
+		}
+	}
+
+	idx.Workspace = workspace
+	sortModulesNestedFirst(idx.Modules)
+
+	return idx, nil
+}
+
+// workspaceModules returns the directories of the modules used by
+// `root/go.work` inside `root`, nil if there is no go.work file
+func workspaceModules(root string) ([]string, error) {
+	file := filepath.Join(root, GoWork)
+
+	//nolint:gosec // go.work of the repository
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	wf, err := modfile.ParseWork(file, content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", file, err.Error())
+	}
+
+	workspace := make([]string, 0, len(wf.Use))
+
+	for _, use := range wf.Use {
+		dir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			// Modules outside of the root own none of its files
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, GoMod)); err != nil {
+			continue
+		}
+
+		if !slices.Contains(workspace, rel) {
+			workspace = append(workspace, rel)
+		}
+	}
+
+	return workspace, nil
+}
+
+// relFile returns the file relative to the root with forward slashes,
+// false if it is outside of the root
+func (idx ModuleIndex) relFile(file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(idx.Root, file)
+		if err != nil {
+			return "", false
+		}
+
+		file = rel
+	}
+
+	file = filepath.ToSlash(filepath.Clean(file))
+
+	if file == ".." || strings.HasPrefix(file, "../") {
+		return "", false
+	}
+
+	return file, true
+}
+
+// ModuleForFile returns the module owning the file, the module with the longest
+// directory containing it. `file` is absolute or relative to the root, and
+// doesn't need to exist. The root module `.` owns files no other module owns.
+// Returns false if the file is outside the root or no module owns it.
+func (idx ModuleIndex) ModuleForFile(file string) (FileModule, bool) {
+	file, ok := idx.relFile(file)
+	if !ok {
+		return FileModule{}, false
+	}
+
+	if idx.Workspace != nil && (file == GoWork || file == GoWorkSum) {
+		return FileModule{Workspace: true}, true
+	}
+
+	found := ""
+	deleted := false
+	ok = false
+
+	match := func(dir string, isDeleted bool) {
+		slashDir := filepath.ToSlash(dir)
+		if slashDir != "." && file != slashDir && !strings.HasPrefix(file, slashDir+"/") {
+			return
+		}
+
+		if !ok || found == "." || len(slashDir) > len(filepath.ToSlash(found)) {
+			found, deleted, ok = dir, isDeleted, true
+		}
+	}
+
+	for _, dir := range idx.Modules {
+		match(dir, false)
+	}
+
+	for _, dir := range idx.Deleted {
+		match(dir, true)
+	}
+
+	return FileModule{Dir: found, Deleted: deleted}, ok
+}
+
+// ModulesForFiles returns the existing modules owning any of the files, sub-modules
+// before their parents. Workspace files select all modules of the workspace.
+func (idx ModuleIndex) ModulesForFiles(files []string) []string {
+	selected := make([]string, 0)
+
+	add := func(dir string) {
+		if !slices.Contains(selected, dir) {
+			selected = append(selected, dir)
+		}
+	}
+
+	for _, file := range files {
+		owner, ok := idx.ModuleForFile(file)
+
+		switch {
+		case !ok || owner.Deleted:
+			continue
+		case owner.Workspace:
+			for _, dir := range idx.Workspace {
+				add(dir)
+			}
+		default:
+			add(owner.Dir)
+		}
+	}
+
+	sortModulesNestedFirst(selected)
+
+	return selected
+}
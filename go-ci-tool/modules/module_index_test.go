@@ -0,0 +1,219 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, file string, content string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(file), 0o750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(file, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModuleForFile(t *testing.T) {
+	root := t.TempDir()
+
+	idx := ModuleIndex{
+		Root: root,
+		// Parents before sub-modules, matching must not depend on the order
+		Modules: []string{".", "a", filepath.Join("a", "b"), "c"},
+		Deleted: []string{filepath.Join("a", "old"), "gone"},
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		want   FileModule
+		wantOK bool
+	}{
+		{"root file", "main.go", FileModule{Dir: "."}, true},
+		{"root go.mod", "go.mod", FileModule{Dir: "."}, true},
+		{"module file", "a/x.go", FileModule{Dir: "a"}, true},
+		{"nested module file", "a/b/x.go", FileModule{Dir: filepath.Join("a", "b")}, true},
+		{"deep nested module file", "a/b/c/d/x.go", FileModule{Dir: filepath.Join("a", "b")}, true},
+		{"nested module go.mod", "a/b/go.mod", FileModule{Dir: filepath.Join("a", "b")}, true},
+		{"module directory itself", "a/b", FileModule{Dir: filepath.Join("a", "b")}, true},
+		{"sibling with common prefix", "a/bc/x.go", FileModule{Dir: "a"}, true},
+		{"directory with module prefix", "cd/x.go", FileModule{Dir: "."}, true},
+		{"unclean path", "./a/../a/b/./x.go", FileModule{Dir: filepath.Join("a", "b")}, true},
+		{"absolute path", filepath.Join(root, "c", "x.go"), FileModule{Dir: "c"}, true},
+		{"deleted module file", "gone/x.go", FileModule{Dir: "gone", Deleted: true}, true},
+		{"deleted nested module file", "a/old/x.go", FileModule{Dir: filepath.Join("a", "old"), Deleted: true}, true},
+		{"go.work without workspace", "go.work", FileModule{Dir: "."}, true},
+		{"outside of root", "../x.go", FileModule{}, false},
+		{"absolute outside of root", filepath.Join(filepath.Dir(root), "x.go"), FileModule{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := idx.ModuleForFile(filepath.FromSlash(tt.file))
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ModuleForFile(%q) = %+v, %t, want %+v, %t", tt.file, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestModuleForFileWithoutRootModule(t *testing.T) {
+	idx := ModuleIndex{Root: t.TempDir(), Modules: []string{"a", filepath.Join("a", "b")}}
+
+	for _, file := range []string{"x.go", "go.mod", "ab/x.go", "b/a/x.go"} {
+		if got, ok := idx.ModuleForFile(file); ok {
+			t.Errorf("ModuleForFile(%q) = %+v, want no module", file, got)
+		}
+	}
+
+	if got, ok := idx.ModuleForFile("a/b/x.go"); !ok || got.Dir != filepath.Join("a", "b") {
+		t.Errorf("ModuleForFile(%q) = %+v, %t, want a/b", "a/b/x.go", got, ok)
+	}
+}
+
+func TestModuleForFileWorkspace(t *testing.T) {
+	idx := ModuleIndex{Root: t.TempDir(), Modules: []string{".", "a"}, Workspace: []string{".", "a"}}
+
+	for _, file := range []string{"go.work", "go.work.sum"} {
+		got, ok := idx.ModuleForFile(file)
+		if !ok || got != (FileModule{Workspace: true}) {
+			t.Errorf("ModuleForFile(%q) = %+v, %t, want workspace file", file, got, ok)
+		}
+	}
+
+	// Only the go.work of the root is a workspace file
+	got, ok := idx.ModuleForFile("a/go.work")
+	if !ok || got != (FileModule{Dir: "a"}) {
+		t.Errorf("ModuleForFile(%q) = %+v, %t, want module a", "a/go.work", got, ok)
+	}
+}
+
+func TestNewModuleIndex(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{".", "a", "a/b", "tools", "testdata/fixture", "node_modules/pkg"} {
+		writeTestFile(t, filepath.Join(root, dir, GoMod), "module example.com/"+dir+"\n")
+	}
+
+	writeTestFile(t, filepath.Join(root, GoWork), `go 1.25
+
+use (
+	.
+	./a
+	./a/b
+	./testdata/fixture
+	./missing
+	../outside
+)
+`)
+
+	idx, err := NewModuleIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantModules := []string{
+		filepath.Join("a", "b"),
+		filepath.Join("testdata", "fixture"),
+		"a",
+		"tools",
+		".",
+	}
+	if !slices.Equal(idx.Modules, wantModules) {
+		t.Errorf("Modules = %q, want %q", idx.Modules, wantModules)
+	}
+
+	wantWorkspace := []string{".", "a", filepath.Join("a", "b"), filepath.Join("testdata", "fixture")}
+	if !slices.Equal(idx.Workspace, wantWorkspace) {
+		t.Errorf("Workspace = %q, want %q", idx.Workspace, wantWorkspace)
+	}
+
+	tests := map[string]string{
+		"testdata/fixture/x.go": filepath.Join("testdata", "fixture"),
+		"testdata/other/x.go":   ".",
+		"node_modules/pkg/x.go": ".",
+		"tools/tools.go":        "tools",
+	}
+
+	for file, want := range tests {
+		got, ok := idx.ModuleForFile(filepath.FromSlash(file))
+		if !ok || got.Dir != want {
+			t.Errorf("ModuleForFile(%q) = %+v, %t, want %s", file, got, ok, want)
+		}
+	}
+}
+
+func TestNewModuleIndexWithoutWorkspace(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "a", GoMod), "module example.com/a\n")
+
+	idx, err := NewModuleIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idx.Workspace != nil {
+		t.Errorf("Workspace = %q, want nil without go.work", idx.Workspace)
+	}
+
+	if _, ok := idx.ModuleForFile(GoWork); ok {
+		t.Errorf("ModuleForFile(%q) found a module without a root module", GoWork)
+	}
+}
+
+func TestNewModuleIndexInvalid(t *testing.T) {
+	if _, err := NewModuleIndex("relative"); err == nil {
+		t.Error("NewModuleIndex accepted a relative root")
+	}
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, GoWork), "use (\n")
+
+	if _, err := NewModuleIndex(root); err == nil {
+		t.Error("NewModuleIndex accepted an invalid go.work")
+	}
+}
+
+func TestModulesForFiles(t *testing.T) {
+	idx := ModuleIndex{
+		Root:      t.TempDir(),
+		Modules:   []string{".", "a", filepath.Join("a", "b"), "c"},
+		Deleted:   []string{"gone"},
+		Workspace: []string{"a", "c"},
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{"no files", nil, []string{}},
+		{"nested before parent", []string{"x.go", "a/x.go", "a/b/x.go"}, []string{filepath.Join("a", "b"), "a", "."}},
+		{"duplicates", []string{"a/x.go", "a/y.go", "a/z/x.go"}, []string{"a"}},
+		{"deleted module", []string{"gone/x.go", "gone/go.mod"}, []string{}},
+		{"outside of root", []string{"../x.go"}, []string{}},
+		{"workspace file", []string{"go.work.sum", "a/b/x.go"}, []string{filepath.Join("a", "b"), "a", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make([]string, 0, len(tt.files))
+			for _, f := range tt.files {
+				files = append(files, filepath.FromSlash(f))
+			}
+
+			got := idx.ModulesForFiles(files)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ModulesForFiles(%q) = %q, want %q", tt.files, got, tt.want)
+			}
+		})
+	}
+}
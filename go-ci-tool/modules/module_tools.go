@@ -0,0 +1,226 @@
+package modules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+)
+
+// toolName returns the name `go tool` accepts for a tool package,
+// the last path element ignoring a major version suffix
+func toolName(pkg string) string {
+	prefix, _, ok := module.SplitPathVersion(pkg)
+	if ok && prefix != "" {
+		return path.Base(prefix)
+	}
+	return path.Base(pkg)
+}
+
+// findDeclaredTool returns the package path of the tool declared in the
+// module matching the given package path or tool name
+func findDeclaredTool(details ModuleDetails, name string) (string, error) {
+	matches := make([]string, 0)
+
+	for _, t := range details.Tools {
+		if t == name {
+			return t, nil
+		}
+
+		if toolName(t) == name {
+			matches = append(matches, t)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("tool %s is not declared in go.mod of module %s", name, details.Module)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf(
+			"tool name %s is ambiguous in module %s, use one of: %s",
+			name,
+			details.Module,
+			strings.Join(matches, ", "),
+		)
+	}
+}
+
+// CheckModuleToolsBuild checks that every tool declared in go.mod builds
+func CheckModuleToolsBuild(details ModuleDetails) error {
+	if len(details.Tools) == 0 {
+		color.Printf(color.InfoColor, "Go module %s doesn't declare any tools\n", details.Module)
+		return nil
+	}
+
+	outDir, err := os.MkdirTemp("", "go-ci-tool-tools-*")
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck // Best effort cleanup of temporary directory
+	defer os.RemoveAll(outDir)
+
+	failed := make([]string, 0)
+
+	for _, tool := range details.Tools {
+		color.Printf(color.InfoColor, "go build %s\n", tool)
+
+		// Trailing separator makes go build write the binary inside the directory
+		cmd := exec.Command(GO, "build", "-o", outDir+string(os.PathSeparator), tool)
+		cmd.Dir = details.ModulePath
+		cmd.Env = append(os.Environ(), GoWorkOff)
+
+		out := bytes.Buffer{}
+		cmd.Stdout = &out
+		cmd.Stderr = &out
+
+		err := cmd.Run()
+
+		// Command failed to run
+		if cmd.ProcessState == nil {
+			return fmt.Errorf(
+				"error while running 'go build %s' for module %s, error: %s",
+				tool,
+				details.Module,
+				err.Error(),
+			)
+		}
+
+		if out.Len() > 0 {
+			color.Print(color.MutedColor, out.String())
+		}
+
+		if cmd.ProcessState.ExitCode() != 0 {
+			color.Printf(color.ErrorColor, "Tool %s failed to build\n", tool)
+			failed = append(failed, tool)
+		}
+	}
+
+	if len(failed) > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"%d of %d tools declared in go module %s failed to build\n",
+			len(failed),
+			len(details.Tools),
+			details.Module,
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"All %d tools declared in go module %s build :)\n",
+		len(details.Tools),
+		details.Module,
+	)
+
+	return nil
+}
+
+// RunModuleTool runs a tool declared in go.mod with `go tool`,
+// connected to the standard input and output
+func RunModuleTool(details ModuleDetails, name string, args []string) error {
+	tool, err := findDeclaredTool(details, name)
+	if err != nil {
+		return err
+	}
+
+	color.Printf(color.InfoColor, "go tool %s %s\n", tool, strings.Join(args, " "))
+
+	//nolint:gosec // Only tools declared in go.mod can be run
+	cmd := exec.Command(GO, append([]string{"tool", tool}, args...)...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return fmt.Errorf(
+			"error while running 'go tool %s' for module %s, error: %s",
+			tool,
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		return fmt.Errorf(
+			"'go tool %s' failed for module %s with exit code %d",
+			tool,
+			details.Module,
+			cmd.ProcessState.ExitCode(),
+		)
+	}
+
+	return nil
+}
+
+func GetRunToolCommand() *cobra.Command {
+	const runToolLongHelpDesc = `
+Run a tool declared with a 'tool' directive in go.mod of the module, using 'go tool' with GOWORK=off.
+Tool can be given by its package path or its name. Arguments after the tool name are passed to the tool.
+Lists the declared tools if no tool is given.
+`
+
+	runToolCommand := &cobra.Command{
+		Use: "run-tool [tool] [args...]",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				if len(moduleDetails.Tools) == 0 {
+					return errors.New("module " + moduleDetails.Module + " doesn't declare any tools")
+				}
+
+				for _, tool := range moduleDetails.Tools {
+					color.Printf(color.InfoColor, "%s (%s)\n", toolName(tool), tool)
+				}
+
+				return nil
+			}
+
+			return RunModuleTool(moduleDetails, args[0], args[1:])
+		},
+		Args: cobra.ArbitraryArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:         "Run a tool declared in go.mod of the module",
+		Long:          runToolLongHelpDesc,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+
+	// Flags after the tool name belong to the tool
+	runToolCommand.Flags().SetInterspersed(false)
+	runToolCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory for which to run the tool. Default is root of current module")
+
+	err := runToolCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return runToolCommand
+}
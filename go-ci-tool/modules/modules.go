@@ -5,6 +5,8 @@
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
 	"github.com/spf13/cobra"
@@ -12,64 +14,128 @@
 
 const (
 	BuildFlag             = "build"
+	BuildToolsFlag        = "build-tools"
+	TargetsFlag           = "targets"
+	CGOEnabledFlag        = "cgo-enabled"
+	TagsFlag              = "tags"
+	ParallelFlag          = "parallel"
+	TagMatrixFlag         = "tag-matrix"
+	TagSetsFlag           = "tag-sets"
+	StepsFlag             = "steps"
 	DownloadFlag          = "download"
 	TestFlag              = "test"
 	FmtFlag               = "fmt"
 	FixFlag               = "fix"
+	DryRunFlag            = "dry-run"
+	LintersFlag           = "linters"
 	LintFlag              = "lint"
 	TidifyFlag            = "tidify"
 	IsTidyFlag            = "is-tidy"
+	IsGeneratedFlag       = "is-generated"
+	VulnFlag              = "vuln"
+	VulnDBFlag            = "vuln-db"
+	VulnAllowlistFlag     = "vuln-allowlist"
+	LicensesFlag          = "licenses"
+	LicensePolicyFlag     = "license-policy"
+	LicenseReportFlag     = "license-report-dir"
 	CheckVersionFlag      = "check-version"
 	CheckLocalReplaceFlag = "check-local-replace"
 	ModuleFlag            = "module"
 	WorkspaceFlag         = "workspace"
 )
 
+// resolveModulePath returns the relative and absolute path of the module
+// given by the module flag, or the root of the current module
+func resolveModulePath(cmd *cobra.Command) (string, string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+
+	modProvided := cmd.Flags().Changed(ModuleFlag)
+	modPath, err := cmd.Flags().GetString(ModuleFlag)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !modProvided {
+		relModulePath, err := FindModuleRoot(cwd)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to find current module root: %s", err.Error())
+		}
+
+		return relModulePath, filepath.Join(cwd, relModulePath), nil
+	}
+
+	if modPath == "" {
+		return "", "", fmt.Errorf(
+			"invalid value empty string provided for 'mod' flag. Omit flag if you want to use current module",
+		)
+	}
+
+	modPath = filepath.Clean(modPath)
+	if filepath.IsAbs(modPath) {
+		return modPath, modPath, nil
+	}
+
+	return modPath, filepath.Join(cwd, modPath), nil
+}
+
+// checkDependentFlags returns an error if a flag only used together with
+// another flag is provided without it
+func checkDependentFlags(cmd *cobra.Command) error {
+	dependentFlags := []struct {
+		flag     string
+		requires string
+	}{
+		{DryRunFlag, FixFlag},
+		{LintersFlag, FixFlag},
+		{TagSetsFlag, TagMatrixFlag},
+		{StepsFlag, TagMatrixFlag},
+		{TargetsFlag, BuildFlag},
+		{CGOEnabledFlag, BuildFlag},
+		{TagsFlag, BuildFlag},
+		{ParallelFlag, BuildFlag},
+		{VulnDBFlag, VulnFlag},
+		{VulnAllowlistFlag, VulnFlag},
+		{LicensePolicyFlag, LicensesFlag},
+		{LicenseReportFlag, LicensesFlag},
+	}
+
+	for _, d := range dependentFlags {
+		if !cmd.Flags().Changed(d.flag) {
+			continue
+		}
+
+		enabled, err := cmd.Flags().GetBool(d.requires)
+		if err != nil {
+			return err
+		}
+
+		if !enabled {
+			return fmt.Errorf("'%s' can only be used with '%s'", d.flag, d.requires)
+		}
+	}
+
+	return nil
+}
+
 //nolint:gocognit,cyclop // No better way to deal wit many flags
 func GetModulesCommand() *cobra.Command {
 	modulesCommand := &cobra.Command{
 		Use: "mod",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cwd, err := os.Getwd()
+			relModulePath, absModulePath, err := resolveModulePath(cmd)
 			if err != nil {
 				return err
 			}
 
-			modProvided := cmd.Flags().Changed(ModuleFlag)
-			modPath, err := cmd.Flags().GetString(ModuleFlag)
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
 			if err != nil {
 				return err
 			}
 
-			// Relative path of module from current directory
-			var relModulePath string
-			// Absolute path of module
-			var absModulePath string
-
-			if modProvided {
-				if modPath == "" {
-					return fmt.Errorf(
-						"invalid value empty string provided for 'mod' flag. Omit flag if you want to use current module",
-					)
-				}
-
-				modPath = filepath.Clean(modPath)
-				if filepath.IsAbs(modPath) {
-					absModulePath = modPath
-					relModulePath = absModulePath
-				} else {
-					relModulePath = modPath
-					absModulePath = filepath.Join(cwd, modPath)
-				}
-			} else {
-				relModulePath, err = FindModuleRoot(cwd)
-				if err != nil {
-					return fmt.Errorf("unable to find current module root: %s", err.Error())
-				}
-				absModulePath = filepath.Join(cwd, relModulePath)
-			}
-
-			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			err = checkDependentFlags(cmd)
 			if err != nil {
 				return err
 			}
@@ -90,6 +156,50 @@ func GetModulesCommand() *cobra.Command {
 				return CheckModuleTidy(moduleDetails)
 			}
 
+			isGenerated, err := cmd.Flags().GetBool(IsGeneratedFlag)
+			if err != nil {
+				return err
+			}
+			if isGenerated {
+				return CheckModuleGenerated(moduleDetails)
+			}
+
+			vuln, err := cmd.Flags().GetBool(VulnFlag)
+			if err != nil {
+				return err
+			}
+			if vuln {
+				db, err := cmd.Flags().GetString(VulnDBFlag)
+				if err != nil {
+					return err
+				}
+
+				allowlist, err := cmd.Flags().GetString(VulnAllowlistFlag)
+				if err != nil {
+					return err
+				}
+
+				return CheckModuleVulnerabilities(moduleDetails, db, allowlist)
+			}
+
+			licenses, err := cmd.Flags().GetBool(LicensesFlag)
+			if err != nil {
+				return err
+			}
+			if licenses {
+				policy, err := cmd.Flags().GetString(LicensePolicyFlag)
+				if err != nil {
+					return err
+				}
+
+				reportDir, err := cmd.Flags().GetString(LicenseReportFlag)
+				if err != nil {
+					return err
+				}
+
+				return CheckModuleLicenses(moduleDetails, policy, reportDir)
+			}
+
 			tidify, err := cmd.Flags().GetBool(TidifyFlag)
 			if err != nil {
 				return err
@@ -106,11 +216,11 @@ func GetModulesCommand() *cobra.Command {
 				return RunGolangCILint(moduleDetails, relModulePath)
 			}
 
-			fmt, err := cmd.Flags().GetBool(FmtFlag)
+			runFmt, err := cmd.Flags().GetBool(FmtFlag)
 			if err != nil {
 				return err
 			}
-			if fmt {
+			if runFmt {
 				return RunGolangCILintFmt(moduleDetails)
 			}
 
@@ -119,7 +229,21 @@ func GetModulesCommand() *cobra.Command {
 				return err
 			}
 			if fix {
-				return RunGolangCILintFix(moduleDetails)
+				linters, err := cmd.Flags().GetStringSlice(LintersFlag)
+				if err != nil {
+					return err
+				}
+
+				dryRun, err := cmd.Flags().GetBool(DryRunFlag)
+				if err != nil {
+					return err
+				}
+
+				if dryRun {
+					return PreviewGolangCILintFix(moduleDetails, linters)
+				}
+
+				return RunGolangCILintFix(moduleDetails, linters)
 			}
 
 			test, err := cmd.Flags().GetBool(TestFlag)
@@ -135,6 +259,24 @@ func GetModulesCommand() *cobra.Command {
 				)
 			}
 
+			tagMatrix, err := cmd.Flags().GetBool(TagMatrixFlag)
+			if err != nil {
+				return err
+			}
+			if tagMatrix {
+				tagSets, err := cmd.Flags().GetStringArray(TagSetsFlag)
+				if err != nil {
+					return err
+				}
+
+				steps, err := cmd.Flags().GetStringSlice(StepsFlag)
+				if err != nil {
+					return err
+				}
+
+				return RunTagMatrix(moduleDetails, ParseTagSets(tagSets), steps)
+			}
+
 			download, err := cmd.Flags().GetBool(DownloadFlag)
 			if err != nil {
 				return err
@@ -148,7 +290,54 @@ func GetModulesCommand() *cobra.Command {
 				return err
 			}
 			if build {
-				return RunModuleBuild(moduleDetails)
+				if !cmd.Flags().Changed(TargetsFlag) &&
+					!cmd.Flags().Changed(CGOEnabledFlag) &&
+					!cmd.Flags().Changed(TagsFlag) {
+					return RunModuleBuild(moduleDetails)
+				}
+
+				targetValues, err := cmd.Flags().GetStringSlice(TargetsFlag)
+				if err != nil {
+					return err
+				}
+
+				targets, err := ParseBuildTargets(targetValues)
+				if err != nil {
+					return err
+				}
+
+				cgoEnabled, err := cmd.Flags().GetString(CGOEnabledFlag)
+				if err != nil {
+					return err
+				}
+				if cgoEnabled != "" && cgoEnabled != "0" && cgoEnabled != "1" {
+					return fmt.Errorf("invalid value %q for '%s', expected 0 or 1", cgoEnabled, CGOEnabledFlag)
+				}
+
+				tags, err := cmd.Flags().GetStringSlice(TagsFlag)
+				if err != nil {
+					return err
+				}
+
+				parallel, err := cmd.Flags().GetInt(ParallelFlag)
+				if err != nil {
+					return err
+				}
+
+				return RunModuleBuildMatrix(moduleDetails, BuildOptions{
+					Targets:    targets,
+					CGOEnabled: cgoEnabled,
+					Tags:       tags,
+					Parallel:   parallel,
+				})
+			}
+
+			buildTools, err := cmd.Flags().GetBool(BuildToolsFlag)
+			if err != nil {
+				return err
+			}
+			if buildTools {
+				return CheckModuleToolsBuild(moduleDetails)
 			}
 
 			checkVersion, err := cmd.Flags().GetBool(CheckVersionFlag)
@@ -169,6 +358,14 @@ func GetModulesCommand() *cobra.Command {
 				moduleDetails.GoVersion,
 			)
 
+			if len(moduleDetails.Tools) > 0 {
+				color.Printf(
+					color.InfoColor,
+					"Tools: %s\n",
+					strings.Join(moduleDetails.Tools, ", "),
+				)
+			}
+
 			return nil
 		},
 		Args: cobra.NoArgs,
@@ -187,16 +384,50 @@ func GetModulesCommand() *cobra.Command {
 	modulesCommand.Flags().
 		Bool(CheckLocalReplaceFlag, false, "Check if module is using any replace directive with a local path")
 	modulesCommand.Flags().Bool(IsTidyFlag, false, "Check if the module is tidy")
+	modulesCommand.Flags().
+		Bool(IsGeneratedFlag, false, "Check if generated files are up to date by running 'go generate' in a scratch copy")
+	modulesCommand.Flags().
+		Bool(VulnFlag, false, "Check for known vulnerabilities reachable from the module code using 'govulncheck'")
+	modulesCommand.Flags().
+		String(VulnDBFlag, os.Getenv("GOVULNDB"), "With 'vuln', local directory or URL of the vulnerability database. Defaults to $GOVULNDB, or the public database if not set")
+	modulesCommand.Flags().
+		String(VulnAllowlistFlag, "", "With 'vuln', JSON file of accepted vulnerabilities with expiry dates. Defaults to "+DefaultVulnAllowlistFile+" in the module root if present")
+	modulesCommand.Flags().
+		Bool(LicensesFlag, false, "Check licenses of dependencies in the module build list against the license policy")
+	modulesCommand.Flags().
+		String(LicensePolicyFlag, "", "With 'licenses', JSON file with allow and deny lists of licenses. Defaults to "+DefaultLicensePolicyFile+" in the module root if present, otherwise GPL and AGPL are denied")
+	modulesCommand.Flags().
+		String(LicenseReportFlag, "", "With 'licenses', directory to write the CSV and JSON license report to")
 	modulesCommand.Flags().Bool(TidifyFlag, false, "Run 'go mod tidy' for the module")
 	modulesCommand.Flags().Bool(LintFlag, false, "Run 'golangci-lint' for the module")
 	modulesCommand.Flags().
 		Bool(FmtFlag, false, "Formats the module using 'golangci-lint'")
 	modulesCommand.Flags().
 		Bool(FixFlag, false, "Fix auto-fixable lint issues in the module")
+	modulesCommand.Flags().
+		Bool(DryRunFlag, false, "With 'fix', show the proposed fixes as a diff grouped by linter without applying them")
+	modulesCommand.Flags().
+		StringSlice(LintersFlag, nil, "With 'fix', only apply fixes from the given linters (comma separated)")
 	modulesCommand.Flags().BoolP(TestFlag, "t", false, "Run Tests for the module")
+	modulesCommand.Flags().
+		Bool(TagMatrixFlag, false, "Find build constraints used in the module and run build, vet and test for each tag combination")
+	modulesCommand.Flags().
+		StringArray(TagSetsFlag, nil, "With 'tag-matrix', a comma separated tag combination, repeat for more combinations. Defaults to no tags, each custom tag and all custom tags")
+	modulesCommand.Flags().
+		StringSlice(StepsFlag, []string{TagStepBuild, TagStepVet, TagStepTest}, "With 'tag-matrix', steps to run for each combination: build, vet, test, lint")
 	modulesCommand.Flags().Bool(DownloadFlag, false, "Download module dependencies")
 	modulesCommand.Flags().
 		BoolP(BuildFlag, "b", false, "Build all the packages in the module")
+	modulesCommand.Flags().
+		StringSlice(TargetsFlag, nil, "With 'build', GOOS/GOARCH targets to cross-compile for (comma separated), 'host' for the current platform")
+	modulesCommand.Flags().
+		String(CGOEnabledFlag, "", "With 'build', CGO_ENABLED value (0 or 1) for all targets")
+	modulesCommand.Flags().
+		StringSlice(TagsFlag, nil, "With 'build', build tags (comma separated)")
+	modulesCommand.Flags().
+		Int(ParallelFlag, runtime.NumCPU(), "With 'build', number of targets to build in parallel")
+	modulesCommand.Flags().
+		Bool(BuildToolsFlag, false, "Check that every tool declared with a 'tool' directive in go.mod builds")
 
 	modulesCommand.Flags().
 		BoolP(WorkspaceFlag, "w", false, "Run the commands in workspace mode")
@@ -207,13 +438,18 @@ func GetModulesCommand() *cobra.Command {
 		CheckVersionFlag,
 		CheckLocalReplaceFlag,
 		IsTidyFlag,
+		IsGeneratedFlag,
+		VulnFlag,
+		LicensesFlag,
 		TidifyFlag,
 		LintFlag,
 		FmtFlag,
 		FixFlag,
 		TestFlag,
+		TagMatrixFlag,
 		DownloadFlag,
 		BuildFlag,
+		BuildToolsFlag,
 	)
 
 	err := modulesCommand.MarkFlagDirname(ModuleFlag)
@@ -227,10 +463,24 @@ func GetModulesCommand() *cobra.Command {
 func GetListModulesCommand() *cobra.Command {
 	const listModulesLongHelpDesc = `
 List Go modules prsent in current or sub-directories. Current directory is only returned if it is a module root.
+With --details every module is listed with its go.mod data, in-repo dependencies, number of packages
+and whether it has tests or main packages. Modules can be filtered by directory glob, module path
+pattern and files changed since a git revision.
+Hidden directories, node_modules, vendor and testdata are never searched, nor directories
+ignored by git. Other directories can be excluded in a .go-ci-modules.json file in the root of the
+git repository (the current directory outside of git), which is read by every command searching
+for modules:
+  {"exclude": ["examples/*", "build"], "includeIgnored": false}
+Patterns match the directory name or its path relative to the root of the repository.
+Sub-modules are listed before their parent modules.
 `
 
 	const (
-		JSONFlag = "json"
+		JSONFlag         = "json"
+		DetailsFlag      = "details"
+		GlobFlag         = "glob"
+		PathFlag         = "path"
+		ChangedSinceFlag = "changed-since"
 	)
 
 	listModulesCommand := &cobra.Command{
@@ -251,8 +501,63 @@ func GetListModulesCommand() *cobra.Command {
 				return err
 			}
 
+			details, err := cmd.Flags().GetBool(DetailsFlag)
+			if err != nil {
+				return err
+			}
+
+			filter := ModuleFilter{}
+
+			filter.Globs, err = cmd.Flags().GetStringArray(GlobFlag)
+			if err != nil {
+				return err
+			}
+
+			filter.PathPatterns, err = cmd.Flags().GetStringArray(PathFlag)
+			if err != nil {
+				return err
+			}
+
+			filter.ChangedSince, err = cmd.Flags().GetString(ChangedSinceFlag)
+			if err != nil {
+				return err
+			}
+
+			selected, err := FilterModules(cwd, allModules, filter)
+			if err != nil {
+				return err
+			}
+
+			var output any = selected
+
+			infos := make([]ModuleInfo, 0, len(selected))
+
+			if details {
+				repoModules := make([]string, 0, len(allModules))
+
+				for _, dir := range allModules {
+					d, err := GetDetailsForModFile(filepath.Join(cwd, dir))
+					if err != nil {
+						return err
+					}
+
+					repoModules = append(repoModules, d.Module)
+				}
+
+				for _, dir := range selected {
+					info, err := GetModuleInfo(cwd, dir, repoModules)
+					if err != nil {
+						return err
+					}
+
+					infos = append(infos, info)
+				}
+
+				output = infos
+			}
+
 			if isJSON {
-				out, err := json.Marshal(allModules)
+				out, err := json.Marshal(output)
 				if err != nil {
 					return fmt.Errorf(
 						"error while formatting modules to JSON: %s",
@@ -267,8 +572,21 @@ func GetListModulesCommand() *cobra.Command {
 						err.Error(),
 					)
 				}
+			} else if details {
+				for _, info := range infos {
+					color.Printf(
+						color.InfoColor,
+						"%s (%s) go %s, %d packages, tests: %t, main: %t\n",
+						info.Dir,
+						info.Module,
+						info.GoVersion,
+						info.Packages,
+						info.HasTests,
+						info.HasMain,
+					)
+				}
 			} else {
-				for _, module := range allModules {
+				for _, module := range selected {
 					color.Println(color.InfoColor, module)
 				}
 			}
@@ -287,6 +605,14 @@ func GetListModulesCommand() *cobra.Command {
 	}
 
 	listModulesCommand.Flags().Bool(JSONFlag, false, "Output in JSON array format")
+	listModulesCommand.Flags().
+		Bool(DetailsFlag, false, "Include go.mod data, in-repo dependencies, packages, tests and main packages of each module")
+	listModulesCommand.Flags().
+		StringArray(GlobFlag, nil, "Only list modules whose directory matches the glob, can be repeated")
+	listModulesCommand.Flags().
+		StringArray(PathFlag, nil, "Only list modules whose module path matches the pattern, can be repeated")
+	listModulesCommand.Flags().
+		String(ChangedSinceFlag, "", "Only list modules with files changed since the git revision")
 
 	return listModulesCommand
 }
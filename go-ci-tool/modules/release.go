@@ -0,0 +1,520 @@
+package modules
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func getReleaseBuildCommand() *cobra.Command {
+	const releaseBuildLongHelpDesc = `
+Build every 'package main' directory of the module with -trimpath for each target platform.
+Version, commit and date are injected with -ldflags '-X', like install.sh sets main.version.
+Binaries of a target, along with LICENSE and README.md of the module, are archived as
+<module>_<version>_<os>_<arch>.tar.gz (.zip for windows) in the dist directory, with a SHA256
+checksums file. Set SOURCE_DATE_EPOCH for reproducible archives.
+`
+
+	const (
+		VersionFlag    = "version"
+		CommitFlag     = "commit"
+		DistFlag       = "dist"
+		CleanFlag      = "clean"
+		VersionVarFlag = "version-var"
+		CommitVarFlag  = "commit-var"
+		DateVarFlag    = "date-var"
+	)
+
+	buildCommand := &cobra.Command{
+		Use: "build",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			opts := ReleaseOptions{}
+
+			version, err := cmd.Flags().GetString(VersionFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.Version, err = ReleaseVersion(version)
+			if err != nil {
+				return err
+			}
+
+			opts.Commit, err = cmd.Flags().GetString(CommitFlag)
+			if err != nil {
+				return err
+			}
+
+			if opts.Commit == "" {
+				opts.Commit, err = HeadCommit(moduleDetails)
+				if err != nil {
+					return err
+				}
+			}
+
+			opts.Date, err = sourceDate()
+			if err != nil {
+				return err
+			}
+
+			targets, err := cmd.Flags().GetString(TargetsFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.Targets, err = ParseBuildTargets(strings.Split(targets, ","))
+			if err != nil {
+				return err
+			}
+
+			opts.CGOEnabled, err = cmd.Flags().GetString(CGOEnabledFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.VersionVar, err = cmd.Flags().GetString(VersionVarFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.CommitVar, err = cmd.Flags().GetString(CommitVarFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.DateVar, err = cmd.Flags().GetString(DateVarFlag)
+			if err != nil {
+				return err
+			}
+
+			opts.DistDir = filepath.Join(absModulePath, DefaultDistDir)
+			if cmd.Flags().Changed(DistFlag) {
+				opts.DistDir, err = cmd.Flags().GetString(DistFlag)
+				if err != nil {
+					return err
+				}
+			}
+
+			clean, err := cmd.Flags().GetBool(CleanFlag)
+			if err != nil {
+				return err
+			}
+
+			if clean {
+				err = CleanDistDir(moduleDetails, opts.DistDir)
+				if err != nil {
+					return err
+				}
+			}
+
+			return BuildRelease(moduleDetails, opts)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Build release archives of the main packages of the module",
+		Long:                  releaseBuildLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	buildCommand.Flags().
+		String(VersionFlag, "", "Version being released, e.g. 2.1.0")
+	buildCommand.Flags().
+		String(CommitFlag, "", "Commit injected into the binaries. Default is HEAD of the repository")
+	buildCommand.Flags().
+		String(TargetsFlag, DefaultReleaseTargets, "Comma separated GOOS/GOARCH pairs to build for")
+	buildCommand.Flags().
+		String(CGOEnabledFlag, "0", "CGO_ENABLED for the builds, empty to keep the environment value")
+	buildCommand.Flags().
+		String(DistFlag, DefaultDistDir, "Directory to write archives and checksums to. Default is 'dist' in the module root")
+	buildCommand.Flags().
+		Bool(CleanFlag, false, "Remove the dist directory before building")
+	buildCommand.Flags().
+		String(VersionVarFlag, "main.version", "Variable set to the version, empty to skip")
+	buildCommand.Flags().
+		String(CommitVarFlag, "main.commit", "Variable set to the commit, empty to skip")
+	buildCommand.Flags().
+		String(DateVarFlag, "main.date", "Variable set to the build date (RFC 3339), empty to skip")
+	buildCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory to release. Default is root of current module")
+
+	err := buildCommand.MarkFlagRequired(VersionFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	err = buildCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return buildCommand
+}
+
+func getReleaseTagCommand() *cobra.Command {
+	const releaseTagLongHelpDesc = `
+Create an annotated tag at HEAD for a release of the module, prefixed with the directory of the module
+in the repository as the Go toolchain expects (e.g. go-ci-tool/v2.1.0). The version must match the
+major version suffix of the module path and be newer than the existing tags of the module.
+Refuses to tag when the working tree has uncommitted changes. The tag is not pushed.
+`
+
+	const (
+		VersionFlag = "version"
+		ListFlag    = "list"
+	)
+
+	tagCommand := &cobra.Command{
+		Use: "tag",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			list, err := cmd.Flags().GetBool(ListFlag)
+			if err != nil {
+				return err
+			}
+
+			if list {
+				return ListModuleTags(moduleDetails)
+			}
+
+			version, err := cmd.Flags().GetString(VersionFlag)
+			if err != nil {
+				return err
+			}
+
+			if version == "" {
+				return errors.New("version is required to tag a release, use --list to see existing tags")
+			}
+
+			dryRun, err := cmd.Flags().GetBool(DryRunFlag)
+			if err != nil {
+				return err
+			}
+
+			return TagModuleRelease(moduleDetails, version, dryRun)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Tag a release of the module or list release tags of all modules",
+		Long:                  releaseTagLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	tagCommand.Flags().
+		String(VersionFlag, "", "Version being released, e.g. 2.1.0")
+	tagCommand.Flags().
+		Bool(ListFlag, false, "List release tags of every module in the repository")
+	tagCommand.Flags().
+		Bool(DryRunFlag, false, "Validate and print the tag without creating it")
+	tagCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory to tag. Default is root of current module")
+
+	tagCommand.MarkFlagsMutuallyExclusive(VersionFlag, ListFlag)
+
+	err := tagCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return tagCommand
+}
+
+func getReleaseAPIDiffCommand() *cobra.Command {
+	const releaseAPIDiffLongHelpDesc = `
+Compare the exported API of the module at its last release tag with the working tree, loading both
+with go/types, and suggest the next version. Internal and main packages are not part of the API.
+Fails on incompatible changes unless the module is at v0 or its path already has a new major version suffix.
+A --base revision that isn't a release tag is compared with the version of the latest release tag
+of the module reachable from it.
+`
+
+	const BaseFlag = "base"
+
+	apiDiffCommand := &cobra.Command{
+		Use: "api-diff",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			base, err := cmd.Flags().GetString(BaseFlag)
+			if err != nil {
+				return err
+			}
+
+			return CheckModuleAPI(moduleDetails, base)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Report API changes since the last release and suggest the next version",
+		Long:                  releaseAPIDiffLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	apiDiffCommand.Flags().
+		String(BaseFlag, "", "Git revision to compare with, a release tag or a revision whose latest reachable release tag is the base version. Default is the latest release tag of the module")
+	apiDiffCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory to compare. Default is root of current module")
+
+	err := apiDiffCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return apiDiffCommand
+}
+
+func getReleaseChangelogCommand() *cobra.Command {
+	const releaseChangelogLongHelpDesc = `
+Render a Markdown changelog section from the commits touching the module directory since its last
+release tag, excluding nested modules. Commits are grouped by conventional commit type
+(feat, fix, perf, ...), with breaking changes listed first. Set SOURCE_DATE_EPOCH to fix the date.
+`
+
+	const (
+		SinceFlag   = "since"
+		VersionFlag = "version"
+		WriteFlag   = "write"
+	)
+
+	changelogCommand := &cobra.Command{
+		Use: "changelog",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			since, err := cmd.Flags().GetString(SinceFlag)
+			if err != nil {
+				return err
+			}
+
+			version, err := cmd.Flags().GetString(VersionFlag)
+			if err != nil {
+				return err
+			}
+
+			write, err := cmd.Flags().GetBool(WriteFlag)
+			if err != nil {
+				return err
+			}
+
+			return GenerateChangelog(moduleDetails, since, version, write)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Generate a changelog of the module since its last release",
+		Long:                  releaseChangelogLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	changelogCommand.Flags().
+		String(SinceFlag, "", "Git revision to start from. Default is the latest release tag of the module")
+	changelogCommand.Flags().
+		String(VersionFlag, "", "Version used as the heading of the section. Default is 'Unreleased'")
+	changelogCommand.Flags().
+		Bool(WriteFlag, false, "Prepend the section to CHANGELOG.md in the module instead of printing it")
+	changelogCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory. Default is root of current module")
+
+	err := changelogCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return changelogCommand
+}
+
+func getReleaseBumpMajorCommand() *cobra.Command {
+	const releaseBumpMajorLongHelpDesc = `
+Move the module to a new major version: the module line of go.mod gets the /vN suffix and every import
+of the module inside it is rewritten. Optionally, other modules of the repository requiring the module
+are moved to the new path, updating their require and replace directives and imports.
+`
+
+	const (
+		MajorFlag            = "major"
+		UpdateDependentsFlag = "update-dependents"
+	)
+
+	bumpMajorCommand := &cobra.Command{
+		Use: "bump-major",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			major, err := cmd.Flags().GetInt(MajorFlag)
+			if err != nil {
+				return err
+			}
+
+			updateDependents, err := cmd.Flags().GetBool(UpdateDependentsFlag)
+			if err != nil {
+				return err
+			}
+
+			dryRun, err := cmd.Flags().GetBool(DryRunFlag)
+			if err != nil {
+				return err
+			}
+
+			return BumpMajorVersion(moduleDetails, major, updateDependents, dryRun)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Move the module to a new major version path",
+		Long:                  releaseBumpMajorLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	bumpMajorCommand.Flags().
+		Int(MajorFlag, 0, "Major version to move to. Default is the next major version")
+	bumpMajorCommand.Flags().
+		Bool(UpdateDependentsFlag, false, "Also update the repository modules that depend on the module")
+	bumpMajorCommand.Flags().
+		Bool(DryRunFlag, false, "List the files that would change without changing them")
+	bumpMajorCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory. Default is root of current module")
+
+	err := bumpMajorCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return bumpMajorCommand
+}
+
+func getReleaseUpdateDependentsCommand() *cobra.Command {
+	const releaseUpdateDependentsLongHelpDesc = `
+Require the given version of the module in every other module of the repository that requires it.
+Each updated module is tidied and built with GOWORK=off, and a report lists the updated modules
+and whether they still build. Modules already requiring the version or a newer one are left as is.
+`
+
+	const VersionFlag = "version"
+
+	updateDependentsCommand := &cobra.Command{
+		Use: "update-dependents",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			version, err := cmd.Flags().GetString(VersionFlag)
+			if err != nil {
+				return err
+			}
+
+			dryRun, err := cmd.Flags().GetBool(DryRunFlag)
+			if err != nil {
+				return err
+			}
+
+			return UpdateDependents(moduleDetails, version, dryRun)
+		},
+		Args:                  cobra.NoArgs,
+		Short:                 "Update the modules of the repository requiring the module to a version",
+		Long:                  releaseUpdateDependentsLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	updateDependentsCommand.Flags().
+		String(VersionFlag, "", "Version of the module to require, e.g. 2.1.0")
+	updateDependentsCommand.Flags().
+		Bool(DryRunFlag, false, "List the modules that would be updated without changing them")
+	updateDependentsCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory of the dependency. Default is root of current module")
+
+	err := updateDependentsCommand.MarkFlagRequired(VersionFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	err = updateDependentsCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return updateDependentsCommand
+}
+
+func GetReleaseCommand() *cobra.Command {
+	const releaseLongHelpDesc = `
+Commands to release the binaries of a module.
+`
+
+	releaseCommand := &cobra.Command{
+		Use: "release",
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Build and tag releases of a module",
+		Long:                  releaseLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	releaseCommand.AddCommand(
+		getReleaseBuildCommand(),
+		getReleaseTagCommand(),
+		getReleaseAPIDiffCommand(),
+		getReleaseChangelogCommand(),
+		getReleaseBumpMajorCommand(),
+		getReleaseUpdateDependentsCommand(),
+	)
+
+	return releaseCommand
+}
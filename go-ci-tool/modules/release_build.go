@@ -0,0 +1,489 @@
+package modules
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	"golang.org/x/mod/semver"
+)
+
+// ReleaseOptions for building release archives of the main packages of a module
+type ReleaseOptions struct {
+	Targets []BuildTarget
+	// Version without the `v` prefix, as install.sh sets it
+	Version string
+	// Commit and Date default to HEAD and $SOURCE_DATE_EPOCH (or now)
+	Commit  string
+	Date    time.Time
+	DistDir string
+	// Fully qualified variables set with `-X`, empty names are skipped
+	VersionVar string
+	CommitVar  string
+	DateVar    string
+	// "0", "1" or empty to keep the environment value
+	CGOEnabled string
+}
+
+type archiveEntry struct {
+	name string
+	file string
+	mode os.FileMode
+}
+
+const (
+	DefaultReleaseTargets = "linux/amd64,linux/arm64,darwin/amd64,darwin/arm64,windows/amd64"
+	DefaultDistDir        = "dist"
+	windowsOS             = "windows"
+	tarGzExt              = ".tar.gz"
+	zipExt                = ".zip"
+	releaseBinaryPerm     = 0o755
+)
+
+// Files from the module root added to every archive if present
+//
+//nolint:gochecknoglobals // Constant list of files
+var releaseExtraFiles = []string{"LICENSE", "README.md"}
+
+// ReleaseVersion validates a semantic version and returns it without the `v` prefix
+func ReleaseVersion(version string) (string, error) {
+	version = strings.TrimPrefix(version, "v")
+
+	if !semver.IsValid("v" + version) {
+		return "", fmt.Errorf("invalid release version %q, expected a semantic version like 2.1.0", version)
+	}
+
+	return version, nil
+}
+
+// HeadCommit returns the commit hash of HEAD of the repository containing the module
+func HeadCommit(details ModuleDetails) (string, error) {
+	out, err := gitOutput(details.ModulePath, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitOutput runs git in `dir` and returns its standard output
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command(GIT, args...)
+	cmd.Dir = dir
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'git %s' in %s, error: %s",
+			strings.Join(args, " "),
+			dir,
+			err.Error(),
+		)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Print(color.MutedColor, errOut.String())
+		return nil, fmt.Errorf("'git %s' failed in %s", strings.Join(args, " "), dir)
+	}
+
+	return out.Bytes(), nil
+}
+
+// findMainPackages returns the import paths of the `package main`
+// directories of the module, failing if two binaries share a name
+func findMainPackages(details ModuleDetails) ([]string, error) {
+	out, err := goCommandOutput(
+		details,
+		"list",
+		"-f",
+		`{{if eq .Name "main"}}{{.ImportPath}}{{end}}`,
+		AllModulesPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make([]string, 0)
+	names := make(map[string]string)
+
+	for line := range strings.Lines(string(out)) {
+		pkg := strings.TrimSpace(line)
+		if pkg == "" {
+			continue
+		}
+
+		name := toolName(pkg)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("main packages %s and %s both build a binary named %s", other, pkg, name)
+		}
+
+		names[name] = pkg
+		packages = append(packages, pkg)
+	}
+
+	return packages, nil
+}
+
+func releaseLDFlags(opts ReleaseOptions) (string, error) {
+	flags := []string{"-s", "-w"}
+
+	values := [][2]string{
+		{opts.VersionVar, opts.Version},
+		{opts.CommitVar, opts.Commit},
+		{opts.DateVar, opts.Date.UTC().Format(time.RFC3339)},
+	}
+
+	for _, v := range values {
+		if v[0] == "" {
+			continue
+		}
+
+		if strings.ContainsFunc(v[0]+v[1], unicode.IsSpace) {
+			return "", fmt.Errorf("ldflags value %s=%s must not contain spaces", v[0], v[1])
+		}
+
+		flags = append(flags, "-X", v[0]+"="+v[1])
+	}
+
+	return strings.Join(flags, " "), nil
+}
+
+func buildReleaseBinary(
+	details ModuleDetails,
+	pkg string,
+	target BuildTarget,
+	opts ReleaseOptions,
+	ldflags string,
+	outFile string,
+) error {
+	color.Printf(color.InfoColor, "go build %s (%s)\n", pkg, target)
+
+	cmd := exec.Command(
+		GO, "build", "-trimpath", "-buildvcs=false", "-ldflags", ldflags, "-o", outFile, pkg,
+	)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	if target.GOOS != "" {
+		cmd.Env = append(cmd.Env, "GOOS="+target.GOOS, "GOARCH="+target.GOARCH)
+	}
+
+	if opts.CGOEnabled != "" {
+		cmd.Env = append(cmd.Env, "CGO_ENABLED="+opts.CGOEnabled)
+	}
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return fmt.Errorf(
+			"error while running 'go build %s' for module %s, error: %s",
+			pkg,
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if out.Len() > 0 {
+		color.Print(color.MutedColor, out.String())
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Printf(color.ErrorColorBold, "%s failed to build for %s\n", pkg, target)
+		return customerrors.NewErrNoLog()
+	}
+
+	return nil
+}
+
+// writeTarGzArchive writes the entries with a fixed timestamp and
+// no ownership, so the same binaries always produce the same archive
+func writeTarGzArchive(file string, entries []archiveEntry, date time.Time) error {
+	//nolint:gosec // Archive inside the dist directory
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = func() error {
+		for _, e := range entries {
+			info, err := os.Stat(e.file)
+			if err != nil {
+				return err
+			}
+
+			err = tw.WriteHeader(&tar.Header{
+				Name:     e.name,
+				Mode:     int64(e.mode),
+				Size:     info.Size(),
+				ModTime:  date,
+				Typeflag: tar.TypeReg,
+				Format:   tar.FormatPAX,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = copyFileTo(tw, e.file)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+
+	return errors.Join(err, tw.Close(), gz.Close(), f.Close())
+}
+
+// writeZipArchive is the Windows counterpart of writeTarGzArchive
+func writeZipArchive(file string, entries []archiveEntry, date time.Time) error {
+	//nolint:gosec // Archive inside the dist directory
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(f)
+
+	err = func() error {
+		for _, e := range entries {
+			header := &zip.FileHeader{
+				Name:     e.name,
+				Method:   zip.Deflate,
+				Modified: date,
+			}
+			header.SetMode(e.mode)
+
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+
+			err = copyFileTo(w, e.file)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+
+	return errors.Join(err, zw.Close(), f.Close())
+}
+
+func copyFileTo(w io.Writer, file string) error {
+	//nolint:gosec // Built binary or file from the module root
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+
+	return errors.Join(err, f.Close())
+}
+
+func fileSHA256(file string) (string, error) {
+	h := sha256.New()
+
+	err := copyFileTo(h, file)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// writeChecksums writes the checksums in the format of `sha256sum`
+func writeChecksums(file string, checksums map[string]string) error {
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	buf := bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", checksums[name], name)
+	}
+
+	return os.WriteFile(file, buf.Bytes(), reportFilePerm)
+}
+
+// CleanDistDir removes the dist directory of the module. Directories that contain
+// the module, including the module root itself, and module roots are refused.
+func CleanDistDir(details ModuleDetails, distDir string) error {
+	dist, err := filepath.Abs(distDir)
+	if err != nil {
+		return err
+	}
+
+	// The module root or a directory inside of it relative to the dist directory
+	rel, err := filepath.Rel(dist, details.ModulePath)
+	parent := ".." + string(filepath.Separator)
+
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, parent) {
+		return fmt.Errorf(
+			"refusing to clean %s, it contains the module %s",
+			dist,
+			details.Module,
+		)
+	}
+
+	_, err = os.Stat(filepath.Join(dist, GoMod))
+	if err == nil {
+		return fmt.Errorf("refusing to clean %s, it is the root of a module", dist)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return os.RemoveAll(dist)
+}
+
+// BuildRelease builds every main package of the module for each target,
+// and writes an archive per target and a SHA256 checksums file to the dist directory
+func BuildRelease(details ModuleDetails, opts ReleaseOptions) error {
+	packages, err := findMainPackages(details)
+	if err != nil {
+		return err
+	}
+
+	if len(packages) == 0 {
+		return fmt.Errorf("go module %s has no main packages to release", details.Module)
+	}
+
+	ldflags, err := releaseLDFlags(opts)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(opts.DistDir, reportDirPerm)
+	if err != nil {
+		return err
+	}
+
+	buildDir, err := os.MkdirTemp("", "go-ci-tool-release-*")
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck // Best effort cleanup of temporary directory
+	defer os.RemoveAll(buildDir)
+
+	extra := make([]archiveEntry, 0, len(releaseExtraFiles))
+
+	for _, name := range releaseExtraFiles {
+		file := filepath.Join(details.ModulePath, name)
+		if _, err := os.Stat(file); err == nil {
+			extra = append(extra, archiveEntry{name: name, file: file, mode: reportFilePerm})
+		}
+	}
+
+	project := toolName(details.Module)
+	checksums := make(map[string]string)
+
+	color.Printf(
+		color.InfoColor,
+		"Releasing %s version %s (commit %s) for %d targets\n",
+		details.Module,
+		opts.Version,
+		opts.Commit,
+		len(opts.Targets),
+	)
+
+	for _, target := range opts.Targets {
+		if target.GOOS == "" {
+			return errors.New("release targets must be GOOS/GOARCH pairs, host is not supported")
+		}
+
+		targetDir := filepath.Join(buildDir, target.GOOS+"_"+target.GOARCH)
+		entries := make([]archiveEntry, 0, len(packages)+len(extra))
+
+		for _, pkg := range packages {
+			binary := toolName(pkg)
+			if target.GOOS == windowsOS {
+				binary += ".exe"
+			}
+
+			outFile := filepath.Join(targetDir, binary)
+
+			err = buildReleaseBinary(details, pkg, target, opts, ldflags, outFile)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, archiveEntry{name: binary, file: outFile, mode: releaseBinaryPerm})
+		}
+
+		entries = append(entries, extra...)
+
+		archive := fmt.Sprintf("%s_%s_%s_%s", project, opts.Version, target.GOOS, target.GOARCH)
+		if target.GOOS == windowsOS {
+			archive += zipExt
+			err = writeZipArchive(filepath.Join(opts.DistDir, archive), entries, opts.Date)
+		} else {
+			archive += tarGzExt
+			err = writeTarGzArchive(filepath.Join(opts.DistDir, archive), entries, opts.Date)
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to write archive %s: %s", archive, err.Error())
+		}
+
+		sum, err := fileSHA256(filepath.Join(opts.DistDir, archive))
+		if err != nil {
+			return err
+		}
+
+		checksums[archive] = sum
+		color.Printf(color.MutedColor, "%s  %s\n", sum, archive)
+	}
+
+	checksumsFile := fmt.Sprintf("%s_%s_checksums.txt", project, opts.Version)
+
+	err = writeChecksums(filepath.Join(opts.DistDir, checksumsFile), checksums)
+	if err != nil {
+		return fmt.Errorf("unable to write checksums file: %s", err.Error())
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Wrote %d archives and %s to %s :)\n",
+		len(checksums),
+		checksumsFile,
+		opts.DistDir,
+	)
+
+	return nil
+}
@@ -0,0 +1,228 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+type dependentUpdate struct {
+	details ModuleDetails
+	from    string
+	status  string
+	failed  bool
+}
+
+const (
+	dependentUpdated  = "updated"
+	dependentUpToDate = "up to date"
+	dependentNewer    = "skipped, requires newer"
+	dependentTidyFail = "tidy failed"
+	dependentBuildOK  = "builds"
+	dependentBuildBad = "build failed"
+)
+
+// requireDependency sets the required version of the dependency in the go.mod of
+// the module in `dir`. Returns the previously required version, empty if the
+// module doesn't require the dependency.
+func requireDependency(
+	dir string,
+	dependency string,
+	version string,
+	dryRun bool,
+) (string, error) {
+	file := filepath.Join(dir, GoMod)
+
+	//nolint:gosec // go.mod of a module in the repository
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := modfile.Parse(file, content, nil)
+	if err != nil {
+		return "", err
+	}
+
+	idx := slices.IndexFunc(f.Require, func(r *modfile.Require) bool { return r.Mod.Path == dependency })
+	if idx < 0 {
+		return "", nil
+	}
+
+	from := f.Require[idx].Mod.Version
+	if semver.Compare(from, version) >= 0 {
+		return from, nil
+	}
+
+	err = f.AddRequire(dependency, version)
+	if err != nil {
+		return "", err
+	}
+
+	return from, writeModFile(file, f, dryRun)
+}
+
+func printDependentUpdates(dependency, version string, updates []dependentUpdate) {
+	rows := make([]color.TableRow, 0, len(updates))
+
+	for _, u := range updates {
+		to := u.from
+		if semver.Compare(u.from, version) < 0 {
+			to = version
+		}
+
+		row := color.TableRow{Color: color.MutedColor, Cells: []string{u.details.Module, u.from, to, u.status}}
+
+		switch {
+		case u.failed:
+			row.Color = color.ErrorColorBold
+		case u.status == dependentBuildOK || u.status == dependentUpdated:
+			row.Color = color.SuccessColor
+		}
+
+		rows = append(rows, row)
+	}
+
+	color.Printf(color.InfoColorBold, "Modules requiring %s\n", dependency)
+	color.PrintTable([]string{"MODULE", "FROM", "TO", "STATUS"}, rows)
+}
+
+// UpdateDependents requires `version` of the module in every other module of the
+// repository requiring it, then tidies and builds each updated module
+func UpdateDependents(details ModuleDetails, version string, dryRun bool) error {
+	version = "v" + strings.TrimPrefix(version, "v")
+	if !semver.IsValid(version) {
+		return fmt.Errorf("invalid version %q, expected a semantic version like 2.1.0", version)
+	}
+
+	_, pathMajor, _ := module.SplitPathVersion(details.Module)
+
+	err := module.CheckPathMajor(version, pathMajor)
+	if err != nil {
+		return fmt.Errorf(
+			"version %s doesn't match the major version suffix of module path %s",
+			version,
+			details.Module,
+		)
+	}
+
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return err
+	}
+
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return err
+	}
+
+	updates := make([]dependentUpdate, 0)
+
+	for _, m := range modules {
+		if m == relDir {
+			continue
+		}
+
+		other, err := GetDetailsForModFile(filepath.Join(root, m))
+		if err != nil {
+			return err
+		}
+
+		from, err := requireDependency(other.ModulePath, details.Module, version, dryRun)
+		if err != nil {
+			return fmt.Errorf("unable to update go.mod of module %s: %s", other.Module, err.Error())
+		}
+
+		if from == "" {
+			continue
+		}
+
+		update := dependentUpdate{details: other, from: from, status: dependentUpdated}
+
+		switch semver.Compare(from, version) {
+		case 0:
+			update.status = dependentUpToDate
+		case 1:
+			update.status = dependentNewer
+		}
+
+		updates = append(updates, update)
+	}
+
+	if len(updates) == 0 {
+		color.Printf(color.InfoColor, "No module of the repository requires %s\n", details.Module)
+		return nil
+	}
+
+	for i, u := range updates {
+		if dryRun || u.status != dependentUpdated {
+			continue
+		}
+
+		color.Printf(color.InfoColorBold, "\nUpdating go module %s to %s %s\n", u.details.Module, details.Module, version)
+
+		err = RunModuleTidy(u.details)
+		if err != nil {
+			color.Printf(color.ErrorColor, "%s\n", err.Error())
+			updates[i].status = dependentTidyFail
+			updates[i].failed = true
+
+			continue
+		}
+
+		err = RunModuleBuild(u.details)
+		if err != nil {
+			color.Printf(color.ErrorColor, "%s\n", err.Error())
+			updates[i].status = dependentBuildBad
+			updates[i].failed = true
+
+			continue
+		}
+
+		updates[i].status = dependentBuildOK
+	}
+
+	color.Println(color.NoColor)
+	printDependentUpdates(details.Module, version, updates)
+	color.Println(color.NoColor)
+
+	failed := 0
+	for _, u := range updates {
+		if u.failed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"%d of %d modules requiring %s failed after the update\n",
+			failed,
+			len(updates),
+			details.Module,
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	if dryRun {
+		color.Printf(color.InfoColorBold, "Dry run, no go.mod was changed\n")
+		return nil
+	}
+
+	color.Printf(color.SuccessColorBold, "Modules requiring %s are on %s :)\n", details.Module, version)
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// RepositoryRoot returns the root of the git repository containing the module
+func RepositoryRoot(details ModuleDetails) (string, error) {
+	out, err := gitOutput(details.ModulePath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(strings.TrimSpace(string(out)))
+}
+
+// ModuleTagPrefix returns the prefix of release tags for a module located at
+// `relDir` from the repository root, e.g. `go-ci-tool/` for `go-ci-tool/v2.1.0`
+func ModuleTagPrefix(relDir string) string {
+	relDir = filepath.ToSlash(filepath.Clean(relDir))
+	if relDir == "." {
+		return ""
+	}
+
+	return relDir + "/"
+}
+
+// moduleRelDir returns the directory of the module relative to the repository
+// root, checking that it is one of the modules found by FindAllModules
+func moduleRelDir(details ModuleDetails, root string) (string, error) {
+	modDir, err := filepath.EvalSymlinks(details.ModulePath)
+	if err != nil {
+		return "", err
+	}
+
+	relDir, err := filepath.Rel(root, modDir)
+	if err != nil || relDir == ".." || strings.HasPrefix(relDir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("module %s is not inside the repository at %s", details.Module, root)
+	}
+
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(modules, relDir) {
+		return "", fmt.Errorf("module %s at %s is not a module of the repository", details.Module, relDir)
+	}
+
+	return relDir, nil
+}
+
+// moduleTags returns the versions of the tags with the prefix, latest first
+func moduleTags(tags []string, prefix string) []string {
+	versions := make([]string, 0)
+
+	for _, tag := range tags {
+		version, ok := strings.CutPrefix(tag, prefix)
+		if !ok || strings.Contains(version, "/") || !semver.IsValid(version) {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	slices.SortFunc(versions, func(a, b string) int { return semver.Compare(b, a) })
+
+	return versions
+}
+
+func gitTags(details ModuleDetails, args ...string) ([]string, error) {
+	args = append([]string{"tag", "--list"}, args...)
+
+	out, err := gitOutput(details.ModulePath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(out)), nil
+}
+
+// LatestModuleTag returns the latest release tag of the module,
+// empty if the module has never been released
+func LatestModuleTag(details ModuleDetails) (string, error) {
+	return latestModuleTag(details)
+}
+
+// LatestModuleTagAt returns the latest release tag of the module reachable
+// from the git revision, empty if there is none
+func LatestModuleTagAt(details ModuleDetails, rev string) (string, error) {
+	return latestModuleTag(details, "--merged", rev)
+}
+
+// latestModuleTag returns the latest release tag of the module
+// among the tags listed by 'git tag --list' with the arguments
+func latestModuleTag(details ModuleDetails, args ...string) (string, error) {
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return "", err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := gitTags(details, args...)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := ModuleTagPrefix(relDir)
+
+	versions := moduleTags(tags, prefix)
+	if len(versions) == 0 {
+		return "", nil
+	}
+
+	return prefix + versions[0], nil
+}
+
+// ListModuleTags prints the release tags of every module of the repository
+func ListModuleTags(details ModuleDetails) error {
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return err
+	}
+
+	modules, err := FindAllModules(root)
+	if err != nil {
+		return err
+	}
+
+	tags, err := gitTags(details)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]color.TableRow, 0, len(modules))
+
+	for _, relDir := range modules {
+		modDetails, err := GetDetailsForModFile(filepath.Join(root, relDir))
+		if err != nil {
+			return err
+		}
+
+		versions := moduleTags(tags, ModuleTagPrefix(relDir))
+
+		latest := "-"
+		if len(versions) > 0 {
+			latest = ModuleTagPrefix(relDir) + versions[0]
+		}
+
+		rows = append(rows, color.TableRow{
+			Color: color.InfoColor,
+			Cells: []string{relDir, modDetails.Module, latest, strings.Join(versions, ", ")},
+		})
+	}
+
+	color.PrintTable([]string{"DIR", "MODULE", "LATEST", "TAGS"}, rows)
+
+	return nil
+}
+
+// TagModuleRelease creates an annotated release tag for the module at HEAD.
+// The version must match the major version suffix of the module path,
+// be newer than the existing tags of the module, and the tree must be clean.
+func TagModuleRelease(details ModuleDetails, version string, dryRun bool) error {
+	version = "v" + strings.TrimPrefix(version, "v")
+	if !semver.IsValid(version) || semver.Canonical(version) != version {
+		return fmt.Errorf("invalid release version %q, expected a semantic version like 2.1.0", version)
+	}
+
+	_, pathMajor, ok := module.SplitPathVersion(details.Module)
+	if !ok {
+		return fmt.Errorf("invalid module path %s", details.Module)
+	}
+
+	err := module.CheckPathMajor(version, pathMajor)
+	if err != nil {
+		return fmt.Errorf(
+			"version %s doesn't match the major version suffix of module path %s",
+			version,
+			details.Module,
+		)
+	}
+
+	root, err := RepositoryRoot(details)
+	if err != nil {
+		return err
+	}
+
+	relDir, err := moduleRelDir(details, root)
+	if err != nil {
+		return err
+	}
+
+	status, err := gitOutput(details.ModulePath, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(status)) > 0 {
+		color.Print(color.MutedColor, string(status))
+		return fmt.Errorf("working tree has uncommitted changes, commit or stash them before tagging %s", details.Module)
+	}
+
+	tags, err := gitTags(details)
+	if err != nil {
+		return err
+	}
+
+	prefix := ModuleTagPrefix(relDir)
+	tag := prefix + version
+
+	versions := moduleTags(tags, prefix)
+	if slices.Contains(versions, version) {
+		return fmt.Errorf("tag %s already exists", tag)
+	}
+
+	if len(versions) > 0 && semver.Compare(version, versions[0]) < 0 {
+		return fmt.Errorf("version %s is older than the latest release %s%s", version, prefix, versions[0])
+	}
+
+	if dryRun {
+		color.Printf(color.InfoColorBold, "Would tag %s as %s\n", details.Module, tag)
+		return nil
+	}
+
+	_, err = gitOutput(details.ModulePath, "tag", "-a", tag, "-m", details.Module+" "+version)
+	if err != nil {
+		return err
+	}
+
+	color.Printf(color.SuccessColorBold, "Tagged %s as %s :)\n", details.Module, tag)
+	color.Printf(color.InfoColor, "Push it with: git push origin %s\n", tag)
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package modules
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RepoModule is a module of the repository
+type RepoModule struct {
+	// Directory relative to the repository root, `.` for the root module
+	Dir  string `json:"dir"`
+	Path string `json:"path"`
+}
+
+// ImportSite is an import of a package of another repository module
+type ImportSite struct {
+	// File relative to the repository root
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Package    string `json:"package"`
+	Import     string `json:"import"`
+	FromModule string `json:"fromModule"`
+	ToModule   string `json:"toModule"`
+}
+
+// RepoGraph of the modules of a repository, with the requirements on each
+// other from go.mod and the package imports between them
+type RepoGraph struct {
+	Root    string
+	Modules []RepoModule
+	// Repository modules required by each module, keyed by module path
+	Requires map[string][]string
+	Imports  []ImportSite
+}
+
+// moduleForImport returns the repository module providing the package,
+// the module with the longest matching path
+func moduleForImport(modules []RepoModule, importPath string) (RepoModule, bool) {
+	found := RepoModule{}
+
+	for _, m := range modules {
+		if (importPath == m.Path || strings.HasPrefix(importPath, m.Path+"/")) && len(m.Path) > len(found.Path) {
+			found = m
+		}
+	}
+
+	return found, found.Path != ""
+}
+
+// BuildRepoGraph finds the modules under `root` and the requirements
+// and package imports between them
+func BuildRepoGraph(root string) (RepoGraph, error) {
+	graph := RepoGraph{Root: root, Requires: make(map[string][]string)}
+
+	dirs, err := FindAllModules(root)
+	if err != nil {
+		return graph, err
+	}
+
+	details := make([]ModuleDetails, 0, len(dirs))
+
+	for _, dir := range dirs {
+		d, err := GetDetailsForModFile(filepath.Join(root, dir))
+		if err != nil {
+			return graph, err
+		}
+
+		details = append(details, d)
+		graph.Modules = append(graph.Modules, RepoModule{Dir: filepath.ToSlash(dir), Path: d.Module})
+	}
+
+	slices.SortFunc(graph.Modules, func(a, b RepoModule) int { return strings.Compare(a.Path, b.Path) })
+
+	for _, d := range details {
+		requires := make([]string, 0)
+
+		for _, r := range d.Requires {
+			if slices.ContainsFunc(graph.Modules, func(m RepoModule) bool { return m.Path == r.Path }) {
+				requires = append(requires, r.Path)
+			}
+		}
+
+		slices.Sort(requires)
+		graph.Requires[d.Module] = requires
+
+		err = walkModuleGoFiles(d.ModulePath, func(path string) error {
+			sites, err := fileImportSites(graph, d, path)
+			graph.Imports = append(graph.Imports, sites...)
+			return err
+		})
+		if err != nil {
+			return graph, err
+		}
+	}
+
+	return graph, nil
+}
+
+func fileImportSites(
+	graph RepoGraph,
+	details ModuleDetails,
+	file string,
+) ([]ImportSite, error) {
+	fset := token.NewFileSet()
+
+	f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", file, err.Error())
+	}
+
+	relFile, err := filepath.Rel(graph.Root, file)
+	if err != nil {
+		return nil, err
+	}
+
+	relDir, err := filepath.Rel(details.ModulePath, filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := details.Module
+	if relDir != "." {
+		pkg += "/" + filepath.ToSlash(relDir)
+	}
+
+	sites := make([]ImportSite, 0)
+
+	for _, imp := range f.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid import %s in %s", imp.Path.Value, file)
+		}
+
+		to, ok := moduleForImport(graph.Modules, importPath)
+		if !ok || to.Path == details.Module {
+			continue
+		}
+
+		sites = append(sites, ImportSite{
+			File:       filepath.ToSlash(relFile),
+			Line:       fset.Position(imp.Pos()).Line,
+			Package:    pkg,
+			Import:     importPath,
+			FromModule: details.Module,
+			ToModule:   to.Path,
+		})
+	}
+
+	return sites, nil
+}
+
+// ImportEdges returns the modules imported by each module
+func (g RepoGraph) ImportEdges() map[string][]string {
+	edges := make(map[string][]string)
+
+	for _, site := range g.Imports {
+		if !slices.Contains(edges[site.FromModule], site.ToModule) {
+			edges[site.FromModule] = append(edges[site.FromModule], site.ToModule)
+		}
+	}
+
+	for from := range edges {
+		slices.Sort(edges[from])
+	}
+
+	return edges
+}
+
+// FindCycles returns the strongly connected components of the graph with
+// more than one node or a self edge, using Tarjan's algorithm
+func FindCycles(nodes []string, edges map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0)
+	cycles := make([][]string, 0)
+
+	var visit func(node string)
+
+	visit = func(node string) {
+		index[node] = len(index)
+		lowLink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range edges[node] {
+			if _, seen := index[next]; !seen {
+				visit(next)
+				lowLink[node] = min(lowLink[node], lowLink[next])
+			} else if onStack[next] {
+				lowLink[node] = min(lowLink[node], index[next])
+			}
+		}
+
+		if lowLink[node] != index[node] {
+			return
+		}
+
+		component := make([]string, 0)
+
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+
+			if top == node {
+				break
+			}
+		}
+
+		if len(component) > 1 || slices.Contains(edges[node], node) {
+			slices.Sort(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, node := range nodes {
+		if _, seen := index[node]; !seen {
+			visit(node)
+		}
+	}
+
+	return cycles
+}
+
+// ModulePaths returns the paths of the modules of the repository
+func (g RepoGraph) ModulePaths() []string {
+	paths := make([]string, 0, len(g.Modules))
+	for _, m := range g.Modules {
+		paths = append(paths, m.Path)
+	}
+
+	return paths
+}
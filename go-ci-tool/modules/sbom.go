@@ -0,0 +1,648 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	"github.com/spf13/cobra"
+)
+
+// sbomComponent is a module in the SBOM, the main module or a dependency
+type sbomComponent struct {
+	path    string
+	version string
+	// h1: hash of the module contents from go.sum. It is a hash of the file
+	// tree (golang.org/x/mod/sumdb/dirhash), not a checksum of any artifact.
+	goSum    string
+	licenses []string
+	purl     string
+	// purls of direct dependencies
+	dependsOn []string
+}
+
+type sbomDocument struct {
+	main      sbomComponent
+	deps      []sbomComponent
+	goVersion string
+	// Go toolchain build settings, e.g. GOOS, GOARCH and CGO_ENABLED
+	buildSettings [][2]string
+	created       time.Time
+	// Deterministic ID derived from the document contents
+	uuid string
+	// Version of go-ci-tool generating the document
+	toolVersion string
+}
+
+// CycloneDX 1.5 JSON, see https://cyclonedx.org/docs/1.5/json/
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Licenses   []cdxLicense  `json:"licenses,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxLicense struct {
+	License cdxLicenseID `json:"license"`
+}
+
+type cdxLicenseID struct {
+	ID string `json:"id"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// SPDX 2.3 JSON, see https://spdx.github.io/spdx-spec/v2.3/
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+	Comment          string            `json:"comment,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const (
+	sbomToolName    = "go-ci-tool"
+	sourceDateEnv   = "SOURCE_DATE_EPOCH"
+	spdxNoAssertion = "NOASSERTION"
+	stdoutFileName  = "-"
+)
+
+//nolint:gochecknoglobals // Build settings included in the SBOM, in order
+var sbomBuildSettingKeys = []string{
+	"GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS",
+	"GOAMD64", "GOARM", "GOARM64", "GO386", "GOEXPERIMENT",
+}
+
+// goCommandOutput runs the go command in the module and returns its output
+func goCommandOutput(details ModuleDetails, args ...string) ([]byte, error) {
+	cmd := exec.Command(GO, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'go %s' for module %s, error: %s",
+			strings.Join(args, " "),
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Print(color.MutedColor, errOut.String())
+		return nil, fmt.Errorf("'go %s' failed for module %s", strings.Join(args, " "), details.Module)
+	}
+
+	return out.Bytes(), nil
+}
+
+// goSumHashes returns the h1: hashes of module contents (not of go.mod
+// files) from go.sum, keyed by `path@version`
+func goSumHashes(modulePath string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	//nolint:gosec // go.sum of the module
+	content, err := os.ReadFile(filepath.Join(modulePath, GoSum))
+	if errors.Is(err, os.ErrNotExist) {
+		return hashes, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		//nolint:mnd // Lines are of the form "<path> <version> <hash>"
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/"+GoMod) {
+			continue
+		}
+
+		hashes[fields[0]+"@"+fields[1]] = fields[2]
+	}
+
+	return hashes, scanner.Err()
+}
+
+// escapeModulePath escapes each element of the module path for URLs
+func escapeModulePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// modulePURL returns the package URL of a Go module
+func modulePURL(path, version string) string {
+	purl := "pkg:golang/" + escapeModulePath(path)
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+
+	return purl
+}
+
+// knownLicenses detects the licenses of the module directory,
+// dropping unrecognized ones
+func knownLicenses(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+
+	licenses, _, err := detectLicenses(dir)
+	if err != nil {
+		return nil
+	}
+
+	return slices.DeleteFunc(licenses, func(l string) bool { return l == unknownLicense })
+}
+
+// sourceDate returns $SOURCE_DATE_EPOCH if set for reproducible
+// builds and documents, otherwise the current time
+func sourceDate() (time.Time, error) {
+	epoch := os.Getenv(sourceDateEnv)
+	if epoch == "" {
+		return time.Now().UTC().Truncate(time.Second), nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s value %q", sourceDateEnv, epoch)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// addDependencyGraph fills dependsOn from `go mod graph`,
+// keeping only edges between the selected module versions
+func addDependencyGraph(details ModuleDetails, doc *sbomDocument) error {
+	out, err := goCommandOutput(details, "mod", "graph")
+	if err != nil {
+		return err
+	}
+
+	// Edges name the required version of the dependency, which MVS may have raised.
+	// Edges are only taken from the selected version of a module, and point
+	// to the selected version of the dependency.
+	selected := map[string]*sbomComponent{doc.main.path: &doc.main}
+	byPath := map[string]*sbomComponent{doc.main.path: &doc.main}
+
+	for i := range doc.deps {
+		selected[doc.deps[i].path+"@"+doc.deps[i].version] = &doc.deps[i]
+		byPath[doc.deps[i].path] = &doc.deps[i]
+	}
+
+	for line := range strings.Lines(string(out)) {
+		fields := strings.Fields(line)
+		//nolint:mnd // Lines are of the form "<from> <to>"
+		if len(fields) != 2 {
+			continue
+		}
+
+		from, ok := selected[fields[0]]
+		if !ok {
+			continue
+		}
+
+		toPath, _, _ := strings.Cut(fields[1], "@")
+
+		to, ok := byPath[toPath]
+		if !ok || slices.Contains(from.dependsOn, to.purl) {
+			continue
+		}
+
+		from.dependsOn = append(from.dependsOn, to.purl)
+	}
+
+	return nil
+}
+
+// buildSBOM collects the modules, hashes, licenses and build settings
+func buildSBOM(details ModuleDetails, mainVersion string) (sbomDocument, error) {
+	doc := sbomDocument{goVersion: details.GoVersion}
+
+	modules, err := listBuildList(details)
+	if err != nil {
+		return doc, err
+	}
+
+	hashes, err := goSumHashes(details.ModulePath)
+	if err != nil {
+		return doc, fmt.Errorf("unable to read go.sum of module %s: %s", details.Module, err.Error())
+	}
+
+	doc.main = sbomComponent{
+		path:     details.Module,
+		version:  mainVersion,
+		licenses: knownLicenses(details.ModulePath),
+		purl:     modulePURL(details.Module, mainVersion),
+	}
+
+	for _, m := range modules {
+		if m.Main {
+			continue
+		}
+
+		// go.sum has the hash of the replacement module
+		dir, sumKey := m.Dir, m.Path+"@"+m.Version
+		if m.Replace != nil {
+			dir, sumKey = m.Replace.Dir, m.Replace.Path+"@"+m.Replace.Version
+		}
+
+		doc.deps = append(doc.deps, sbomComponent{
+			path:     m.Path,
+			version:  m.Version,
+			goSum:    hashes[sumKey],
+			licenses: knownLicenses(dir),
+			purl:     modulePURL(m.Path, m.Version),
+		})
+	}
+
+	err = addDependencyGraph(details, &doc)
+	if err != nil {
+		return doc, err
+	}
+
+	envOut, err := goCommandOutput(details, append([]string{"env", "-json"}, sbomBuildSettingKeys...)...)
+	if err != nil {
+		return doc, err
+	}
+
+	env := make(map[string]string)
+
+	err = json.Unmarshal(envOut, &env)
+	if err != nil {
+		return doc, fmt.Errorf("unable to parse 'go env' output: %s", err.Error())
+	}
+
+	for _, key := range sbomBuildSettingKeys {
+		if env[key] != "" {
+			doc.buildSettings = append(doc.buildSettings, [2]string{key, env[key]})
+		}
+	}
+
+	doc.created, err = sourceDate()
+	if err != nil {
+		return doc, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s@%s %s\n", doc.main.path, doc.main.version, doc.created.Format(time.RFC3339))
+	for _, dep := range doc.deps {
+		fmt.Fprintf(h, "%s@%s %s\n", dep.path, dep.version, dep.goSum)
+	}
+
+	sum := h.Sum(nil)
+	// Format as a version 4 style UUID
+	sum[6] = (sum[6] & 0x0f) | 0x40 //nolint:mnd // UUID version bits
+	sum[8] = (sum[8] & 0x3f) | 0x80 //nolint:mnd // UUID variant bits
+	doc.uuid = fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	return doc, nil
+}
+
+func (c sbomComponent) cycloneDX(componentType string) cdxComponent {
+	component := cdxComponent{
+		Type:    componentType,
+		BOMRef:  c.purl,
+		Name:    c.path,
+		Version: c.version,
+		PURL:    c.purl,
+	}
+
+	if c.goSum != "" {
+		component.Properties = []cdxProperty{{Name: "go:sum:h1", Value: c.goSum}}
+	}
+
+	for _, license := range c.licenses {
+		component.Licenses = append(component.Licenses, cdxLicense{License: cdxLicenseID{ID: license}})
+	}
+
+	return component
+}
+
+func (doc sbomDocument) cycloneDX() cdxBOM {
+	main := doc.main.cycloneDX("application")
+	main.Properties = []cdxProperty{{Name: "go:module:go_version", Value: doc.goVersion}}
+
+	for _, setting := range doc.buildSettings {
+		main.Properties = append(main.Properties, cdxProperty{Name: "go:build:" + setting[0], Value: setting[1]})
+	}
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + doc.uuid,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: doc.created.Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{{Type: "application", Name: sbomToolName, Version: doc.toolVersion}},
+			},
+			Component: main,
+		},
+		Components:   make([]cdxComponent, 0, len(doc.deps)),
+		Dependencies: make([]cdxDependency, 0, len(doc.deps)+1),
+	}
+
+	for _, c := range append([]sbomComponent{doc.main}, doc.deps...) {
+		if c.path != doc.main.path {
+			bom.Components = append(bom.Components, c.cycloneDX("library"))
+		}
+
+		dependsOn := c.dependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{Ref: c.purl, DependsOn: dependsOn})
+	}
+
+	return bom
+}
+
+func (c sbomComponent) spdx(id string) spdxPackage {
+	pkg := spdxPackage{
+		Name:             c.path,
+		SPDXID:           id,
+		VersionInfo:      c.version,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+		ExternalRefs: []spdxExternalRef{{
+			ReferenceCategory: "PACKAGE-MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  c.purl,
+		}},
+	}
+
+	if c.goSum != "" {
+		pkg.Comment = "go.sum hash of the module file tree: " + c.goSum
+	}
+
+	if len(c.licenses) > 0 {
+		pkg.LicenseDeclared = strings.Join(c.licenses, " AND ")
+	}
+
+	return pkg
+}
+
+func (doc sbomDocument) spdx() spdxDocument {
+	settings := make([]string, 0, len(doc.buildSettings))
+	for _, setting := range doc.buildSettings {
+		settings = append(settings, setting[0]+"="+setting[1])
+	}
+
+	document := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              doc.main.path,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + escapeModulePath(doc.main.path) + "-" + doc.uuid,
+		CreationInfo: spdxCreationInfo{
+			Created:  doc.created.Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolName + "-" + doc.toolVersion},
+			Comment:  "Build settings: " + strings.Join(settings, " "),
+		},
+		Packages:      make([]spdxPackage, 0, len(doc.deps)+1),
+		Relationships: make([]spdxRelationship, 0),
+	}
+
+	ids := make(map[string]string)
+	components := append([]sbomComponent{doc.main}, doc.deps...)
+
+	for i, c := range components {
+		ids[c.purl] = "SPDXRef-Package-" + strconv.Itoa(i)
+		document.Packages = append(document.Packages, c.spdx(ids[c.purl]))
+	}
+
+	document.Packages[0].Comment = "Go version: " + doc.goVersion
+
+	document.Relationships = append(document.Relationships, spdxRelationship{
+		SPDXElementID:      document.SPDXID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: ids[doc.main.purl],
+	})
+
+	for _, c := range components {
+		for _, dep := range c.dependsOn {
+			document.Relationships = append(document.Relationships, spdxRelationship{
+				SPDXElementID:      ids[c.purl],
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: ids[dep],
+			})
+		}
+	}
+
+	return document
+}
+
+// writeJSONFile writes the value as indented JSON, `-` writes to stdout
+func writeJSONFile(file string, value any) error {
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	out = append(out, '\n')
+
+	if file == stdoutFileName {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	return os.WriteFile(file, out, reportFilePerm)
+}
+
+func GetSBOMCommand() *cobra.Command {
+	const sbomLongHelpDesc = `
+Generate a software bill of materials for the module from its build list ('go list -m all') and go.sum hashes.
+Covers the main module, all dependencies with their detected licenses, the dependency graph,
+the Go version of the module and the build settings of the Go toolchain.
+Set SOURCE_DATE_EPOCH for reproducible documents.
+`
+
+	const (
+		CycloneDXFlag = "cyclonedx"
+		SPDXFlag      = "spdx"
+		VersionFlag   = "version"
+	)
+
+	sbomCommand := &cobra.Command{
+		Use: "sbom",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, absModulePath, err := resolveModulePath(cmd)
+			if err != nil {
+				return err
+			}
+
+			moduleDetails, err := GetDetailsForModFile(absModulePath)
+			if err != nil {
+				return err
+			}
+
+			cycloneDXFile, err := cmd.Flags().GetString(CycloneDXFlag)
+			if err != nil {
+				return err
+			}
+
+			spdxFile, err := cmd.Flags().GetString(SPDXFlag)
+			if err != nil {
+				return err
+			}
+
+			if cycloneDXFile == stdoutFileName && spdxFile == stdoutFileName {
+				return errors.New("only one of the SBOM documents can be written to stdout")
+			}
+
+			version, err := cmd.Flags().GetString(VersionFlag)
+			if err != nil {
+				return err
+			}
+
+			doc, err := buildSBOM(moduleDetails, version)
+			if err != nil {
+				return err
+			}
+
+			doc.toolVersion = cmd.Root().Version
+			if doc.toolVersion == "" {
+				doc.toolVersion = "devel"
+			}
+
+			if cycloneDXFile != "" {
+				err = writeJSONFile(cycloneDXFile, doc.cycloneDX())
+				if err != nil {
+					return fmt.Errorf("unable to write CycloneDX SBOM: %s", err.Error())
+				}
+			}
+
+			if spdxFile != "" {
+				err = writeJSONFile(spdxFile, doc.spdx())
+				if err != nil {
+					return fmt.Errorf("unable to write SPDX SBOM: %s", err.Error())
+				}
+			}
+
+			return nil
+		},
+		Args: cobra.NoArgs,
+		CompletionOptions: cobra.CompletionOptions{
+			DisableDefaultCmd: true,
+		},
+		Short:                 "Generate CycloneDX and SPDX SBOMs for the module",
+		Long:                  sbomLongHelpDesc,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		DisableFlagsInUseLine: true,
+	}
+
+	sbomCommand.Flags().
+		String(CycloneDXFlag, "", "File to write the CycloneDX JSON document to, '-' for stdout")
+	sbomCommand.Flags().
+		String(SPDXFlag, "", "File to write the SPDX JSON document to, '-' for stdout")
+	sbomCommand.Flags().
+		String(VersionFlag, "", "Version of the module being released")
+	sbomCommand.Flags().
+		StringP(ModuleFlag, "m", "", "Path to the module root directory. Default is root of current module")
+
+	sbomCommand.MarkFlagsOneRequired(CycloneDXFlag, SPDXFlag)
+
+	err := sbomCommand.MarkFlagDirname(ModuleFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	return sbomCommand
+}
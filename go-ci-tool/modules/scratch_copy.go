@@ -0,0 +1,253 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+type ScratchModule struct {
+	Dir string
+	// Content of files rewritten while setting up the scratch copy,
+	// keyed by the slash separated path relative to module root
+	originalContent  map[string]string
+	rewrittenContent map[string]string
+}
+
+const (
+	GitDir                = ".git"
+	scratchDirPattern     = "go-ci-tool-scratch-*"
+	readAllOwnerWritePerm = fs.FileMode(0o644)
+)
+
+// isLocalPath reports whether a replace directive path points to
+// a local directory rather than a module path
+func isLocalPath(p string) bool {
+	return p == "." || p == ".." ||
+		strings.HasPrefix(p, "./") ||
+		strings.HasPrefix(p, "../")
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	//nolint:gosec // src is a file inside the module being copied
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck // Read only file
+
+	//nolint:gosec // dst is inside the scratch directory we created
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+
+	return errors.Join(err, out.Close())
+}
+
+// rewriteLocalReplaces makes relative replace directives in the copied go.mod
+// absolute, so that they still resolve from the scratch directory
+func rewriteLocalReplaces(originalDir string, scratch *ScratchModule) error {
+	goModPath := filepath.Join(scratch.Dir, GoMod)
+
+	//nolint:gosec // go.mod inside the scratch directory we created
+	content, err := os.ReadFile(goModPath)
+	if err != nil {
+		return err
+	}
+
+	f, err := modfile.Parse(GoMod, content, nil)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+
+	for _, r := range f.Replace {
+		if !isLocalPath(r.New.Path) {
+			continue
+		}
+
+		absPath := filepath.Join(originalDir, filepath.FromSlash(r.New.Path))
+
+		err = f.AddReplace(r.Old.Path, r.Old.Version, absPath, "")
+		if err != nil {
+			return err
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	out, err := f.Format()
+	if err != nil {
+		return err
+	}
+
+	scratch.originalContent[GoMod] = string(content)
+	scratch.rewrittenContent[GoMod] = string(out)
+
+	return os.WriteFile(goModPath, out, readAllOwnerWritePerm)
+}
+
+// CopyModuleToScratch copies the files belonging to the module into a new
+// temporary directory. Nested modules and `.git` are not copied.
+// Relative local replaces in go.mod are made absolute in the copy.
+// Caller should call Remove on the returned value once done.
+func CopyModuleToScratch(details ModuleDetails) (ScratchModule, error) {
+	scratchDir, err := os.MkdirTemp("", scratchDirPattern)
+	if err != nil {
+		return ScratchModule{}, fmt.Errorf(
+			"unable to create scratch directory: %s",
+			err.Error(),
+		)
+	}
+
+	scratch := ScratchModule{
+		Dir:              scratchDir,
+		originalContent:  make(map[string]string),
+		rewrittenContent: make(map[string]string),
+	}
+
+	err = filepath.WalkDir(
+		details.ModulePath,
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(details.ModulePath, path)
+			if err != nil {
+				return err
+			}
+
+			target := filepath.Join(scratchDir, rel)
+
+			if d.IsDir() {
+				if d.Name() == GitDir {
+					return filepath.SkipDir
+				}
+
+				// Nested module, not part of this module
+				if rel != "." {
+					_, err := os.Stat(filepath.Join(path, GoMod))
+					if err == nil {
+						return filepath.SkipDir
+					}
+				}
+
+				return os.MkdirAll(target, fs.ModePerm)
+			}
+
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			return copyFile(path, target, info.Mode().Perm())
+		},
+	)
+	if err == nil {
+		err = rewriteLocalReplaces(details.ModulePath, &scratch)
+	}
+
+	if err != nil {
+		scratch.Remove()
+
+		return ScratchModule{}, fmt.Errorf(
+			"unable to copy module %s to scratch directory: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	return scratch, nil
+}
+
+// Files returns the contents of the files in the scratch copy.
+// Files rewritten during setup are reported with their original content
+// as long as they have not been modified since.
+func (s ScratchModule) Files() (map[string]string, error) {
+	files, err := ListModuleFiles(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, rewritten := range s.rewrittenContent {
+		if content, ok := files[name]; ok && content == rewritten {
+			files[name] = s.originalContent[name]
+		}
+	}
+
+	return files, nil
+}
+
+// Remove deletes the scratch directory
+func (s ScratchModule) Remove() {
+	//nolint:errcheck,gosec // Best effort cleanup of temporary directory
+	os.RemoveAll(s.Dir)
+}
+
+// ListModuleFiles returns the contents of all regular files in the module
+// keyed by their slash separated path relative to the module root.
+// Nested modules and `.git` are skipped.
+func ListModuleFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if d.Name() == GitDir {
+				return filepath.SkipDir
+			}
+
+			if path != dir {
+				_, err := os.Stat(filepath.Join(path, GoMod))
+				if err == nil {
+					return filepath.SkipDir
+				}
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		//nolint:gosec // Reading files inside the module
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = string(content)
+
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,466 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+)
+
+// BuildConstraints found in the files of a module.
+// Values are the number of files using the tag.
+type BuildConstraints struct {
+	// Tags only enabled with -tags, e.g. integration or purego
+	Custom map[string]int
+	// GOOS and GOARCH values, including file name suffixes
+	Platform map[string]int
+	// Tags set by the toolchain, e.g. cgo, gc, go1.21, goexperiment.*
+	Toolchain map[string]int
+}
+
+type tagStepResult struct {
+	step   string
+	passed bool
+	output string
+}
+
+// Steps run for every tag combination
+const (
+	TagStepBuild = "build"
+	TagStepVet   = "vet"
+	TagStepTest  = "test"
+	TagStepLint  = "lint"
+)
+
+const noTagsLabel = "(none)"
+
+// supportedPlatforms returns the GOOS/GOARCH pairs supported by the toolchain
+func supportedPlatforms() ([]string, error) {
+	out, err := exec.Command(GO, "tool", "dist", "list").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list supported platforms: %s", err.Error())
+	}
+
+	return strings.Fields(string(out)), nil
+}
+
+// platformNames returns the known GOOS and GOARCH values
+func platformNames() (map[string]bool, error) {
+	platforms, err := supportedPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	// unix is satisfied by all Unix-like GOOS values
+	names := map[string]bool{"unix": true}
+
+	for _, platform := range platforms {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		names[goos] = true
+		names[goarch] = true
+	}
+
+	return names, nil
+}
+
+func isToolchainTag(tag string) bool {
+	return tag == "cgo" || tag == "gc" || tag == "gccgo" || tag == "ignore" ||
+		strings.HasPrefix(tag, "go1.") || strings.HasPrefix(tag, "goexperiment.")
+}
+
+// fileConstraint returns the build constraint of the Go source,
+// which must appear before the package clause
+func fileConstraint(content []byte) (constraint.Expr, error) {
+	var plusBuild constraint.Expr
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+
+		if constraint.IsGoBuild(line) {
+			return constraint.Parse(line)
+		}
+
+		// Only used by files that predate //go:build lines
+		if constraint.IsPlusBuild(line) {
+			expr, err := constraint.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+
+			if plusBuild == nil {
+				plusBuild = expr
+			} else {
+				plusBuild = &constraint.AndExpr{X: plusBuild, Y: expr}
+			}
+		}
+	}
+
+	return plusBuild, scanner.Err()
+}
+
+func collectTags(expr constraint.Expr, tags map[string]bool) {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		tags[e.Tag] = true
+	case *constraint.NotExpr:
+		collectTags(e.X, tags)
+	case *constraint.AndExpr:
+		collectTags(e.X, tags)
+		collectTags(e.Y, tags)
+	case *constraint.OrExpr:
+		collectTags(e.X, tags)
+		collectTags(e.Y, tags)
+	}
+}
+
+// fileNameTags returns the GOOS/GOARCH from name_GOOS_GOARCH.go style names
+func fileNameTags(name string, platforms map[string]bool) []string {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".go"), "_test")
+
+	parts := strings.Split(name, "_")
+	tags := make([]string, 0)
+
+	//nolint:mnd // Only the last two elements can be GOOS and GOARCH
+	for i := max(len(parts)-2, 1); i < len(parts); i++ {
+		if platforms[parts[i]] && parts[i] != "unix" {
+			tags = append(tags, parts[i])
+		}
+	}
+
+	return tags
+}
+
+// walkModuleGoFiles calls `fn` for every Go file of the module in `dir`.
+// Nested modules, vendor, testdata and hidden directories are skipped.
+func walkModuleGoFiles(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+
+			name := d.Name()
+			if name == "vendor" || name == "testdata" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+
+			if _, err := os.Stat(filepath.Join(path, GoMod)); err == nil {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".go") {
+			return nil
+		}
+
+		return fn(path)
+	})
+}
+
+// FindBuildConstraints finds the build tags used by the Go files of the
+// module. Nested modules, vendor, testdata and hidden directories are skipped.
+func FindBuildConstraints(details ModuleDetails) (BuildConstraints, error) {
+	found := BuildConstraints{
+		Custom:    make(map[string]int),
+		Platform:  make(map[string]int),
+		Toolchain: make(map[string]int),
+	}
+
+	platforms, err := platformNames()
+	if err != nil {
+		return found, err
+	}
+
+	err = walkModuleGoFiles(details.ModulePath, func(path string) error {
+		//nolint:gosec // Reading files inside the module
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		expr, err := fileConstraint(content)
+		if err != nil {
+			return fmt.Errorf("invalid build constraint in %s: %s", path, err.Error())
+		}
+
+		tags := make(map[string]bool)
+
+		if expr != nil {
+			collectTags(expr, tags)
+		}
+
+		for _, tag := range fileNameTags(filepath.Base(path), platforms) {
+			tags[tag] = true
+		}
+
+		for tag := range tags {
+			switch {
+			case platforms[tag]:
+				found.Platform[tag]++
+			case isToolchainTag(tag):
+				found.Toolchain[tag]++
+			default:
+				found.Custom[tag]++
+			}
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+// DefaultTagSets returns no tags, each custom tag alone and all of them
+// together
+func DefaultTagSets(found BuildConstraints) [][]string {
+	custom := slices.Sorted(maps.Keys(found.Custom))
+	sets := [][]string{{}}
+
+	for _, tag := range custom {
+		sets = append(sets, []string{tag})
+	}
+
+	if len(custom) > 1 {
+		sets = append(sets, custom)
+	}
+
+	return sets
+}
+
+// ParseTagSets parses comma separated tag combinations, an empty value
+// is the combination without any tags
+func ParseTagSets(values []string) [][]string {
+	sets := make([][]string, 0, len(values))
+
+	for _, value := range values {
+		set := make([]string, 0)
+
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !slices.Contains(set, tag) {
+				set = append(set, tag)
+			}
+		}
+
+		slices.Sort(set)
+		sets = append(sets, set)
+	}
+
+	return sets
+}
+
+func printTagCounts(title string, tags map[string]int) {
+	if len(tags) == 0 {
+		return
+	}
+
+	counts := make([]string, 0, len(tags))
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		counts = append(counts, fmt.Sprintf("%s (%d)", tag, tags[tag]))
+	}
+
+	color.Printf(color.InfoColor, "  %s: %s\n", title, strings.Join(counts, ", "))
+}
+
+func tagStepArgs(step string, tags []string) (string, []string) {
+	tagList := strings.Join(tags, ",")
+
+	switch step {
+	case TagStepLint:
+		args := []string{"run"}
+		if tagList != "" {
+			args = append(args, "--build-tags", tagList)
+		}
+		return GolangCILint, append(args, AllModulesPath)
+	case TagStepBuild:
+		// Discard the results, see buildForTarget
+		args := []string{"build", "-o", os.DevNull}
+		if tagList != "" {
+			args = append(args, "-tags", tagList)
+		}
+		return GO, append(args, AllModulesPath)
+	default:
+		args := []string{step}
+		if tagList != "" {
+			args = append(args, "-tags", tagList)
+		}
+		return GO, append(args, AllModulesPath)
+	}
+}
+
+func runTagStep(
+	details ModuleDetails,
+	step string,
+	tags []string,
+) (tagStepResult, error) {
+	name, args := tagStepArgs(step, tags)
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return tagStepResult{}, fmt.Errorf(
+			"error while running '%s %s' for module %s, error: %s",
+			name,
+			strings.Join(args, " "),
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	return tagStepResult{
+		step:   step,
+		passed: cmd.ProcessState.ExitCode() == 0,
+		output: out.String(),
+	}, nil
+}
+
+func tagSetLabel(tags []string) string {
+	if len(tags) == 0 {
+		return noTagsLabel
+	}
+	return strings.Join(tags, ",")
+}
+
+func printTagMatrix(sets [][]string, steps []string, results [][]tagStepResult) {
+	rows := make([]color.TableRow, 0, len(sets))
+
+	for i, set := range sets {
+		row := color.TableRow{Color: color.SuccessColor, Cells: []string{tagSetLabel(set)}}
+
+		for _, r := range results[i] {
+			if r.passed {
+				row.Cells = append(row.Cells, "pass")
+			} else {
+				row.Cells = append(row.Cells, "fail")
+				row.Color = color.ErrorColorBold
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	header := []string{"TAGS"}
+	for _, step := range steps {
+		header = append(header, strings.ToUpper(step))
+	}
+
+	color.PrintTable(header, rows)
+}
+
+// RunTagMatrix runs the steps (build, vet, test, lint) for every tag
+// combination. Defaults to the combinations from DefaultTagSets.
+func RunTagMatrix(details ModuleDetails, sets [][]string, steps []string) error {
+	for _, step := range steps {
+		if !slices.Contains([]string{TagStepBuild, TagStepVet, TagStepTest, TagStepLint}, step) {
+			return fmt.Errorf("unknown step %q, expected one of build, vet, test, lint", step)
+		}
+	}
+
+	found, err := FindBuildConstraints(details)
+	if err != nil {
+		return err
+	}
+
+	color.Printf(color.InfoColorBold, "Build constraints used in go module %s\n", details.Module)
+
+	if len(found.Custom)+len(found.Platform)+len(found.Toolchain) == 0 {
+		color.Println(color.InfoColor, "  none")
+	}
+
+	printTagCounts("custom", found.Custom)
+	printTagCounts("platform", found.Platform)
+	printTagCounts("toolchain", found.Toolchain)
+
+	if len(found.Platform) > 0 {
+		color.Println(color.MutedColor, "  Platform tags are covered by building with '--build --targets'")
+	}
+
+	if len(sets) == 0 {
+		sets = DefaultTagSets(found)
+	}
+
+	results := make([][]tagStepResult, len(sets))
+	failed := 0
+
+	for i, set := range sets {
+		color.Printf(color.InfoColorBold, "\nTags: %s\n", tagSetLabel(set))
+
+		setFailed := false
+
+		for _, step := range steps {
+			color.Printf(color.InfoColor, "%s\n", step)
+
+			result, err := runTagStep(details, step, set)
+			if err != nil {
+				return err
+			}
+
+			if result.output != "" {
+				color.Print(color.MutedColor, result.output)
+			}
+
+			results[i] = append(results[i], result)
+			setFailed = setFailed || !result.passed
+		}
+
+		if setFailed {
+			failed++
+		}
+	}
+
+	color.Println(color.NoColor)
+	printTagMatrix(sets, steps, results)
+	color.Println(color.NoColor)
+
+	if failed > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"Go module %s failed for %d of %d tag combinations\n",
+			details.Module,
+			failed,
+			len(sets),
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(
+		color.SuccessColorBold,
+		"Go module %s passed for all %d tag combinations :)\n",
+		details.Module,
+		len(sets),
+	)
+
+	return nil
+}
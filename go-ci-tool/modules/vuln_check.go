@@ -0,0 +1,432 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+	customerrors "github.com/ram-nad/go-monorepo/go-ci-tool/v2/custom_errors"
+)
+
+// Subset of the govulncheck JSON output.
+// See https://pkg.go.dev/golang.org/x/vuln/internal/govulncheck
+type vulnMessage struct {
+	OSV     *vulnOSV     `json:"osv,omitempty"`
+	Finding *vulnFinding `json:"finding,omitempty"`
+}
+
+type vulnOSV struct {
+	ID      string   `json:"id"`
+	Summary string   `json:"summary"`
+	Aliases []string `json:"aliases"`
+}
+
+type vulnFinding struct {
+	OSV          string      `json:"osv"`
+	FixedVersion string      `json:"fixed_version"`
+	Trace        []vulnFrame `json:"trace"`
+}
+
+type vulnFrame struct {
+	Module   string        `json:"module"`
+	Version  string        `json:"version"`
+	Package  string        `json:"package"`
+	Function string        `json:"function"`
+	Receiver string        `json:"receiver"`
+	Position *vulnPosition `json:"position,omitempty"`
+}
+
+type vulnPosition struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+}
+
+// VulnAllowlist lists vulnerabilities accepted as known risks
+type VulnAllowlist struct {
+	Entries []VulnAllowlistEntry `json:"entries"`
+}
+
+type VulnAllowlistEntry struct {
+	// OSV ID (GO-YYYY-NNNN) or one of its aliases (CVE, GHSA)
+	ID string `json:"id"`
+	// Optional module path the entry is limited to
+	Module string `json:"module,omitempty"`
+	Reason string `json:"reason"`
+	// Last day (YYYY-MM-DD, UTC) the entry is valid on
+	Expires string `json:"expires"`
+}
+
+// Called vulnerability with an example call stack
+type calledVuln struct {
+	osv     vulnOSV
+	module  string
+	version string
+	fixed   string
+	// Frames from the entry point in the module to the vulnerable symbol
+	stack []vulnFrame
+}
+
+const (
+	GoVulnCheck = "govulncheck"
+	// Allowlist looked up in the module root if none is provided
+	DefaultVulnAllowlistFile = ".vuln-allowlist.json"
+	allowlistDateLayout      = "2006-01-02"
+)
+
+// LoadVulnAllowlist reads and validates the allowlist file
+func LoadVulnAllowlist(path string) (VulnAllowlist, error) {
+	allowlist := VulnAllowlist{}
+
+	//nolint:gosec // Allowlist path provided by the user
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return allowlist, fmt.Errorf("unable to read vulnerability allowlist %s: %s", path, err.Error())
+	}
+
+	err = json.Unmarshal(content, &allowlist)
+	if err != nil {
+		return allowlist, fmt.Errorf("unable to parse vulnerability allowlist %s: %s", path, err.Error())
+	}
+
+	for _, entry := range allowlist.Entries {
+		if entry.ID == "" {
+			return allowlist, fmt.Errorf("entry without id in vulnerability allowlist %s", path)
+		}
+
+		if entry.Reason == "" {
+			return allowlist, fmt.Errorf("entry %s in vulnerability allowlist %s has no reason", entry.ID, path)
+		}
+
+		_, err := time.Parse(allowlistDateLayout, entry.Expires)
+		if err != nil {
+			return allowlist, fmt.Errorf(
+				"entry %s in vulnerability allowlist %s has invalid expiry date %q, expected YYYY-MM-DD",
+				entry.ID,
+				path,
+				entry.Expires,
+			)
+		}
+	}
+
+	return allowlist, nil
+}
+
+// expired reports whether the entry is no longer valid at `now`
+func (e VulnAllowlistEntry) expired(now time.Time) bool {
+	expires, err := time.Parse(allowlistDateLayout, e.Expires)
+	if err != nil {
+		return true
+	}
+
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// find returns the allowlist entry matching the vulnerability, if any
+func (a VulnAllowlist) find(v calledVuln) (VulnAllowlistEntry, bool) {
+	ids := append([]string{v.osv.ID}, v.osv.Aliases...)
+
+	for _, entry := range a.Entries {
+		if entry.Module != "" && entry.Module != v.module {
+			continue
+		}
+
+		if slices.Contains(ids, entry.ID) {
+			return entry, true
+		}
+	}
+
+	return VulnAllowlistEntry{}, false
+}
+
+// vulnDBArg converts a local database directory into a file URL
+// accepted by govulncheck, URLs are used as is
+func vulnDBArg(db string) (string, error) {
+	u, err := url.Parse(db)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+		return db, nil
+	}
+
+	absDB, err := filepath.Abs(db)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(absDB)
+	if err != nil {
+		return "", fmt.Errorf("unable to use vulnerability database %s: %s", db, err.Error())
+	}
+
+	if !info.IsDir() {
+		return "", fmt.Errorf("vulnerability database %s is not a directory", db)
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absDB)}).String(), nil
+}
+
+// parseVulnOutput reads the govulncheck JSON stream and returns the
+// vulnerabilities reachable from the module code, and the number of
+// vulnerabilities that are only imported or required
+func parseVulnOutput(r io.Reader) ([]calledVuln, int, error) {
+	osvs := make(map[string]vulnOSV)
+	called := make(map[string]*calledVuln)
+	notCalled := make(map[string]bool)
+	order := make([]string, 0)
+
+	decoder := json.NewDecoder(r)
+
+	for {
+		msg := vulnMessage{}
+
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to parse govulncheck output: %s", err.Error())
+		}
+
+		if msg.OSV != nil {
+			osvs[msg.OSV.ID] = *msg.OSV
+		}
+
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+
+		// First frame is the vulnerable symbol, it has a function
+		// only if the symbol is reachable from the module code
+		if f.Trace[0].Function == "" {
+			notCalled[f.OSV] = true
+			continue
+		}
+
+		if _, ok := called[f.OSV]; ok {
+			continue
+		}
+
+		stack := slices.Clone(f.Trace)
+		slices.Reverse(stack)
+
+		called[f.OSV] = &calledVuln{
+			module:  f.Trace[0].Module,
+			version: f.Trace[0].Version,
+			fixed:   f.FixedVersion,
+			stack:   stack,
+		}
+		order = append(order, f.OSV)
+	}
+
+	result := make([]calledVuln, 0, len(order))
+
+	for _, id := range order {
+		v := called[id]
+		v.osv = osvs[id]
+		v.osv.ID = id
+		result = append(result, *v)
+
+		delete(notCalled, id)
+	}
+
+	return result, len(notCalled), nil
+}
+
+func (f vulnFrame) String() string {
+	name := f.Function
+	if f.Receiver != "" {
+		name = strings.TrimPrefix(f.Receiver, "*") + "." + name
+	}
+
+	if f.Package != "" {
+		name = f.Package + "." + name
+	}
+
+	if f.Position != nil && f.Position.Filename != "" {
+		name = fmt.Sprintf("%s (%s:%d)", name, filepath.Base(f.Position.Filename), f.Position.Line)
+	}
+
+	return name
+}
+
+func printCalledVuln(v calledVuln, accepted bool) {
+	title := v.osv.ID
+	if len(v.osv.Aliases) > 0 {
+		title += " (" + strings.Join(v.osv.Aliases, ", ") + ")"
+	}
+
+	if accepted {
+		color.Printf(color.WarningColorBold, "\n%s\n", title)
+	} else {
+		color.Printf(color.ErrorColorBold, "\n%s\n", title)
+	}
+
+	if v.osv.Summary != "" {
+		color.Printf(color.InfoColor, "  %s\n", v.osv.Summary)
+	}
+
+	fixed := v.fixed
+	if fixed == "" {
+		fixed = "not fixed"
+	}
+
+	color.Printf(color.InfoColor, "  Module:        %s\n", v.module)
+	color.Printf(color.InfoColor, "  Found in:      %s\n", v.version)
+	color.Printf(color.InfoColor, "  Fixed in:      %s\n", fixed)
+	color.Println(color.InfoColor, "  Call stack:")
+
+	for _, frame := range v.stack {
+		color.Printf(color.MutedColor, "    %s\n", frame)
+	}
+}
+
+// loadModuleVulnAllowlist loads the allowlist at `allowlistPath`, or the default
+// allowlist of the module if present when no path is given
+func loadModuleVulnAllowlist(
+	details ModuleDetails,
+	allowlistPath string,
+) (VulnAllowlist, error) {
+	if allowlistPath == "" {
+		defaultPath := filepath.Join(details.ModulePath, DefaultVulnAllowlistFile)
+		if _, err := os.Stat(defaultPath); err != nil {
+			return VulnAllowlist{}, nil
+		}
+
+		allowlistPath = defaultPath
+	}
+
+	return LoadVulnAllowlist(allowlistPath)
+}
+
+// runGovulncheck returns the JSON output of govulncheck for the module
+func runGovulncheck(details ModuleDetails, db string) (*bytes.Buffer, error) {
+	args := []string{"-format", "json"}
+
+	if db != "" {
+		dbArg, err := vulnDBArg(db)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, "-db", dbArg)
+	}
+
+	args = append(args, AllModulesPath)
+
+	cmd := exec.Command(GoVulnCheck, args...)
+	cmd.Dir = details.ModulePath
+	cmd.Env = append(os.Environ(), GoWorkOff)
+
+	out := bytes.Buffer{}
+	errOut := bytes.Buffer{}
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+
+	err := cmd.Run()
+
+	// Command failed to run
+	if cmd.ProcessState == nil {
+		return nil, fmt.Errorf(
+			"error while running 'govulncheck' for module %s, error: %s",
+			details.Module,
+			err.Error(),
+		)
+	}
+
+	if cmd.ProcessState.ExitCode() != 0 {
+		color.Print(color.MutedColor, errOut.String())
+		return nil, fmt.Errorf("'govulncheck' failed for module %s", details.Module)
+	}
+
+	return &out, nil
+}
+
+// CheckModuleVulnerabilities runs govulncheck against the given database
+// and fails for vulnerabilities reachable from the module code that are
+// not in the allowlist
+func CheckModuleVulnerabilities(
+	details ModuleDetails,
+	db string,
+	allowlistPath string,
+) error {
+	color.Println(color.InfoColor, "govulncheck ./...")
+
+	_, err := exec.LookPath(GoVulnCheck)
+	if err != nil {
+		return fmt.Errorf(
+			"%s is not installed, add it to the tool manifest or install it with 'go install golang.org/x/vuln/cmd/govulncheck@latest'",
+			GoVulnCheck,
+		)
+	}
+
+	allowlist, err := loadModuleVulnAllowlist(details, allowlistPath)
+	if err != nil {
+		return err
+	}
+
+	out, err := runGovulncheck(details, db)
+	if err != nil {
+		return err
+	}
+
+	called, notCalled, err := parseVulnOutput(out)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	failed := 0
+
+	for _, v := range called {
+		entry, ok := allowlist.find(v)
+
+		switch {
+		case ok && !entry.expired(now):
+			printCalledVuln(v, true)
+			color.Printf(color.WarningColor, "  Accepted until %s: %s\n", entry.Expires, entry.Reason)
+		case ok:
+			failed++
+
+			printCalledVuln(v, false)
+			color.Printf(color.ErrorColor, "  Allowlist entry expired on %s: %s\n", entry.Expires, entry.Reason)
+		default:
+			failed++
+
+			printCalledVuln(v, false)
+		}
+	}
+
+	color.Println(color.NoColor)
+
+	if notCalled > 0 {
+		color.Printf(
+			color.InfoColor,
+			"%d vulnerabilities in imported packages or required modules are not called by the code\n",
+			notCalled,
+		)
+	}
+
+	if failed > 0 {
+		color.Printf(
+			color.ErrorColorBold,
+			"Go module %s calls %d vulnerable symbols that are not accepted\n",
+			details.Module,
+			failed,
+		)
+		return customerrors.NewErrNoLog()
+	}
+
+	color.Printf(color.SuccessColorBold, "No unaccepted vulnerabilities found for go module %s :)\n", details.Module)
+
+	return nil
+}
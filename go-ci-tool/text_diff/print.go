@@ -0,0 +1,26 @@
+package textdiff
+
+import (
+	"strings"
+
+	"github.com/ram-nad/go-monorepo/go-ci-tool/v2/color"
+)
+
+// PrintColored prints a unified diff with added, removed and
+// hunk header lines highlighted
+func PrintColored(diff string) {
+	for _, line := range SplitLines(diff) {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			color.Print(color.HighLightColorBold, line)
+		case strings.HasPrefix(line, "@@"):
+			color.Print(color.InfoColor, line)
+		case strings.HasPrefix(line, "+"):
+			color.Print(color.SuccessColor, line)
+		case strings.HasPrefix(line, "-"):
+			color.Print(color.ErrorColor, line)
+		default:
+			color.Print(color.MutedColor, line)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package textdiff produces unified diffs for text files
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opKind int
+
+type edit struct {
+	kind opKind
+	line string
+}
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+const DefaultContextLines = 3
+
+// SplitLines splits text into lines, keeping the line terminators,
+// so that the diff preserves a missing newline at the end of file
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// computeEdits returns the shortest edit script to turn `a` into `b`
+// using the Myers diff algorithm
+func computeEdits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+
+	if maxD == 0 {
+		return nil
+	}
+
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+	trace := make([][]int, 0)
+
+	found := false
+
+	for d := 0; d <= maxD && !found; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk back through the trace to build the edit script
+	edits := make([]edit, 0, n+m)
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{kind: opEqual, line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{kind: opInsert, line: b[y]})
+			} else {
+				x--
+				edits = append(edits, edit{kind: opDelete, line: a[x]})
+			}
+		}
+	}
+
+	// Reverse to get edits in order
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range starts at the line before the change
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// Unified returns the unified diff between `oldText` and `newText` with
+// `oldName` and `newName` as file names in the header.
+// Returns empty string if the texts are the same.
+func Unified(oldName, newName, oldText, newText string, contextLines int) string {
+	if oldText == newText {
+		return ""
+	}
+
+	edits := computeEdits(SplitLines(oldText), SplitLines(newText))
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	i := 0
+	// Current line index in old and new text
+	oldLine, newLine := 0, 0
+
+	for i < len(edits) {
+		// Skip to the next change
+		if edits[i].kind == opEqual {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Start the hunk with the leading context
+		start := max(i-contextLines, 0)
+
+		hunkOldStart := oldLine - (i - start)
+		hunkNewStart := newLine - (i - start)
+
+		// Extend the hunk till we find more than 2 * context equal lines
+		end := i
+		equalRun := 0
+
+		for end < len(edits) {
+			if edits[end].kind == opEqual {
+				if equalRun == 2*contextLines {
+					break
+				}
+				equalRun++
+			} else {
+				equalRun = 0
+			}
+			end++
+		}
+
+		// Trim trailing context
+		trailing := min(equalRun, contextLines)
+		end = end - equalRun + trailing
+
+		oldCount, newCount := 0, 0
+		body := strings.Builder{}
+
+		for _, e := range edits[start:end] {
+			switch e.kind {
+			case opEqual:
+				oldCount++
+				newCount++
+				writeLine(&body, ' ', e.line)
+			case opDelete:
+				oldCount++
+				writeLine(&body, '-', e.line)
+			case opInsert:
+				newCount++
+				writeLine(&body, '+', e.line)
+			default:
+				// All kinds are handled above
+			}
+		}
+
+		fmt.Fprintf(
+			&sb,
+			"@@ -%s +%s @@\n",
+			hunkRange(hunkOldStart, oldCount),
+			hunkRange(hunkNewStart, newCount),
+		)
+		sb.WriteString(body.String())
+
+		// Move past the lines consumed by this hunk (except the leading context)
+		for _, e := range edits[i:end] {
+			switch e.kind {
+			case opEqual:
+				oldLine++
+				newLine++
+			case opDelete:
+				oldLine++
+			case opInsert:
+				newLine++
+			default:
+				// All kinds are handled above
+			}
+		}
+
+		i = end
+	}
+
+	return sb.String()
+}
+
+// FileDiffs returns unified diffs for every file that differs between
+// `oldFiles` and `newFiles`. Both input and output are keyed by
+// the slash separated relative path of the file.
+func FileDiffs(oldFiles, newFiles map[string]string) map[string]string {
+	diffs := make(map[string]string)
+
+	for name, oldContent := range oldFiles {
+		newContent, ok := newFiles[name]
+		newName := "b/" + name
+
+		if !ok {
+			newName = "/dev/null"
+		}
+
+		diff := Unified("a/"+name, newName, oldContent, newContent, DefaultContextLines)
+		if diff != "" {
+			diffs[name] = diff
+		}
+	}
+
+	for name, newContent := range newFiles {
+		if _, ok := oldFiles[name]; ok {
+			continue
+		}
+
+		diff := Unified("/dev/null", "b/"+name, "", newContent, DefaultContextLines)
+		if diff != "" {
+			diffs[name] = diff
+		}
+	}
+
+	return diffs
+}
@@ -0,0 +1,107 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		context int
+		want    string
+	}{
+		{
+			name:    "identical",
+			oldText: "a\nb\n",
+			newText: "a\nb\n",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "empty old",
+			oldText: "",
+			newText: "a\nb\n",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "empty new",
+			oldText: "a\nb\n",
+			newText: "",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name:    "old missing newline",
+			oldText: "a\nb",
+			newText: "a\nb\n",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:    "new missing newline",
+			oldText: "a\nb\n",
+			newText: "a\nb",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name:    "both missing newline",
+			oldText: "a\nb",
+			newText: "a\nc",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name:    "first line",
+			oldText: "1\n2\n3\n4\n5\n6\n",
+			newText: "x\n2\n3\n4\n5\n6\n",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n",
+		},
+		{
+			name:    "last line",
+			oldText: "1\n2\n3\n4\n5\n6\n",
+			newText: "1\n2\n3\n4\n5\nx\n",
+			context: 3,
+			want:    "--- a/f\n+++ b/f\n@@ -3,4 +3,4 @@\n 3\n 4\n 5\n-6\n+x\n",
+		},
+		{
+			name:    "merge at 2 context",
+			oldText: "1\n2\n3\n4\n5\n6\n7\n",
+			newText: "x\n2\n3\n4\n5\ny\n7\n",
+			context: 2,
+			want:    "--- a/f\n+++ b/f\n@@ -1,7 +1,7 @@\n-1\n+x\n 2\n 3\n 4\n 5\n-6\n+y\n 7\n",
+		},
+		{
+			name:    "split past 2 context",
+			oldText: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			newText: "x\n2\n3\n4\n5\n6\ny\n8\n",
+			context: 2,
+			want:    "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n-1\n+x\n 2\n 3\n@@ -5,4 +5,4 @@\n 5\n 6\n-7\n+y\n 8\n",
+		},
+		{
+			name:    "insert in middle",
+			oldText: "1\n2\n3\n",
+			newText: "1\n2\nx\n3\n",
+			context: 1,
+			want:    "--- a/f\n+++ b/f\n@@ -2,2 +2,3 @@\n 2\n+x\n 3\n",
+		},
+		{
+			name:    "zero context",
+			oldText: "1\n2\n3\n",
+			newText: "1\nx\n3\n",
+			context: 0,
+			want:    "--- a/f\n+++ b/f\n@@ -2 +2 @@\n-2\n+x\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified("a/f", "b/f", tt.oldText, tt.newText, tt.context)
+			if got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -23,16 +23,6 @@ else
     return
 fi
 
-# Check if golangci-lint is installed and matches the required version
-if (golangci-lint version >/dev/null 2>&1) && [ $(golangci-lint version --short) == $GOLANGCI_LINT_VERSION ]
-then
-    echo "[install] Golang CI Lint is already installed"
-    true
-else
-    echo "[install] Installing Golang CI Lint v$GOLANGCI_LINT_VERSION"
-    curl -sSfL https://raw.githubusercontent.com/golangci/golangci-lint/master/install.sh | sh -s -- -b $GOBIN_PATH v$GOLANGCI_LINT_VERSION
-fi
-
 # Build the go-tool tool
 echo "[install] Building the go-ci-tool :)"
 
@@ -41,3 +31,8 @@ GO_MIN_VERSION_SUBSTITUTION="github.com/ram-nad/go-monorepo/go-ci-tool/v2/consta
 GOLANG_CI_LINT_VERSION_SUBSTITUTION="github.com/ram-nad/go-monorepo/go-ci-tool/v2/constants.minGolangCILintVersion=$GOLANGCI_LINT_VERSION"
 
 GOWORK=off go install -C go-ci-tool -trimpath -buildvcs=false -ldflags="-w -X $VERSION_SUBSTITUION -X $GO_MIN_VERSION_SUBSTITUTION -X $GOLANG_CI_LINT_VERSION_SUBSTITUTION" .
+
+# Install golangci-lint if it is missing or doesn't match the required version
+# Set GOLANGCI_LINT_MIRROR to download from a mirror or a local directory
+echo "[install] Checking tools and installing Golang CI Lint v$GOLANGCI_LINT_VERSION if required"
+go-ci-tool check-tools --install